@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/runlock"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// Exit codes of every command.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+	// exitLocked means another run holds the run lock, so nothing was done.
+	exitLocked = 3
+)
+
+// Global flags, given before the command name.
+var configPath string
+var credentialsDir string
+var dryRun bool
+var logFormat string
+
+// jsonOut is where machine readable output goes with -log-format json. It is
+// nil for text logs, which like the progress logged with the standard logger
+// go to stdout.
+var jsonOut io.Writer
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(args []string) int
+}
+
+func commandList() []command {
+	return []command{
+		{"sweep", "[-incremental] [-resume] [-metrics-addr addr]", "go through the procurement folders once (the default)", sweepCommand},
+		{"serve", "[-incremental] [-resume] [-metrics-addr addr]", "run sweeps on the configured schedule and serve /run, /status, /report and /metrics", serveCommand},
+		{"status", "", "show whether a run is in progress and how the last one ended", statusCommand},
+		{"export", "[-out file]", "export the SKUs and costs of parsed cost sheets to CSV", exportCommand},
+		{"cost-override", "", "send the costs of cost sheets not yet submitted to SkuVault; needs USER_TOKEN and TENANT_TOKEN in the environment or .env", costOverrideCommand},
+		{"send-cost-sheet", "[url]", "send the costs of one cost sheet to SkuVault; needs USER_TOKEN and TENANT_TOKEN in the environment or .env, and only SheetCert.json", sendCostSheetCommand},
+		{"po-lookup", "po-of <folder or sheet ID or URL> | folder-of <PO number>", "find the PO of a folder or the folder of a PO", poLookupCommand},
+		{"help", "[command]", "show this help or a command's flags", helpCommand},
+	}
+}
+
+// globalFlags binds the global flags to a new flag set.
+func globalFlags() *flag.FlagSet {
+	global := flag.NewFlagSet("drive-parser", flag.ContinueOnError)
+	global.StringVar(&configPath, "config", models.DefaultConfigPath, "path of the config file")
+	global.StringVar(&credentialsDir, "credentials", ".", "directory holding the Google service account keys cert.json (Drive) and SheetCert.json (Sheets)")
+	global.BoolVar(&dryRun, "dry-run", false, "log what would be done without changing Drive, Insightly or SkuVault")
+	global.StringVar(&logFormat, "log-format", "text", "text, or json for one JSON object per folder and for the statistics on stdout, with everything else on stderr")
+	global.Usage = func() {
+		printUsage(global)
+	}
+	return global
+}
+
+func main() {
+	global := globalFlags()
+	err := global.Parse(os.Args[1:])
+	if err != nil {
+		os.Exit(usageExit(err))
+	}
+	log.SetFlags(0)
+	log.SetOutput(os.Stdout)
+	switch logFormat {
+	case "text":
+	case "json":
+		// Keep stdout for the JSON objects alone.
+		jsonOut = os.Stdout
+		log.SetOutput(os.Stderr)
+	default:
+		fmt.Printf("unknown log format %q, expected text or json\n", logFormat)
+		os.Exit(exitUsage)
+	}
+
+	name := "sweep"
+	args := global.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	for _, c := range commandList() {
+		if c.name == name {
+			os.Exit(c.run(args))
+		}
+	}
+	fmt.Printf("unknown command %q\n", name)
+	printUsage(global)
+	os.Exit(exitUsage)
+}
+
+func printUsage(global *flag.FlagSet) {
+	fmt.Println("Usage: drive-parser [global flags] [command] [command flags]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, c := range commandList() {
+		fmt.Printf("  %-16s %s\n", c.name, c.summary)
+	}
+	fmt.Println()
+	fmt.Println("Global flags:")
+	global.SetOutput(os.Stdout)
+	global.PrintDefaults()
+	fmt.Println()
+	fmt.Println("Exit codes: 0 done, 1 failed, 2 bad usage, 3 another run holds the run lock")
+}
+
+func helpCommand(args []string) int {
+	if len(args) == 0 {
+		printUsage(globalFlags())
+		return exitOK
+	}
+	for _, c := range commandList() {
+		if c.name == args[0] {
+			fmt.Printf("Usage: drive-parser [global flags] %s %s\n", c.name, c.args)
+			fmt.Println()
+			fmt.Println(strings.ToUpper(c.summary[:1]) + c.summary[1:])
+			if c.name != "help" {
+				c.run([]string{"-h"})
+			}
+			return exitOK
+		}
+	}
+	fmt.Printf("unknown command %q\n", args[0])
+	return exitUsage
+}
+
+// usageExit is the exit code for a flag parsing error; asking for help is
+// not a failure.
+func usageExit(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+// lockedExit is the exit code for a command that could not start.
+func lockedExit(err error) int {
+	var locked *runlock.LockedError
+	if errors.As(err, &locked) {
+		return exitLocked
+	}
+	return exitFailed
+}
+
+// acquireLock takes the run lock for one of the tools that change SkuVault.
+// The returned code is non-zero when the command should stop.
+func acquireLock(name string) (*runlock.Lock, int) {
+	lock, err := runlock.Acquire(runlock.DefaultPath, name)
+	if err != nil {
+		log.Println(err)
+		return nil, lockedExit(err)
+	}
+	if lock.Stale != nil {
+		log.Println("Taking over the run lock left by ", *lock.Stale)
+	}
+	return lock, exitOK
+}
+
+// commandFlags returns the flag set of a command, printing its flags to
+// stdout when asked for help.
+func commandFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(os.Stdout)
+	flags.Usage = func() {
+		fmt.Printf("Flags of %s:\n", name)
+		flags.PrintDefaults()
+	}
+	return flags
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/modules"
+	drive "google.golang.org/api/drive/v3"
+	"log"
+	"strings"
+	"time"
+)
+
+// costOverridePause is how many seconds cost-override waits between cost
+// sheets, and the step of its retry backoff. It is fixed, as it was before
+// the sleep time out came from the config.
+const costOverridePause = 1
+
+func countDownTimer(duration int) {
+	for i := duration; i > 0; i-- {
+		// print on the same line
+		fmt.Fprintf(log.Writer(), "\rSleeping for %d seconds ", i)
+		time.Sleep(time.Second)
+	}
+}
+
+// getFolders lists every folder under the Procurement folder.
+func getFolders() ([]*drive.File, error) {
+	folders := make(chan *drive.File)
+	listErr := make(chan error, 1)
+	go func() {
+		listErr <- listProcurementFolders(folders)
+	}()
+	var fileList []*drive.File
+	for folder := range folders {
+		fileList = append(fileList, folder)
+	}
+	return fileList, <-listErr
+}
+
+// costOverrideCommand sends the costs of the cost sheets listed as not yet
+// submitted in ./json/parsedFiles.json to SkuVault.
+func costOverrideCommand(args []string) int {
+	flags := commandFlags("cost-override")
+	err := flags.Parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	lock, code := acquireLock("cost-override")
+	if code != exitOK {
+		return code
+	}
+	defer lock.Release()
+	var p models.ParsedDrivesJson
+	p.GetDrives()
+	err = loadConfig()
+	if err == nil {
+		err = checkSkuVaultTokens()
+	}
+	if err == nil {
+		err = setupGoogle()
+	}
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+
+	folders, err := getFolders()
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+
+	costSheetsToSubmit := p.CostSheetsNotSubmitted
+	var foldersToParse []*drive.File
+OUTER:
+	for _, costSheet := range costSheetsToSubmit {
+		costSheetParentFolder := strings.TrimSpace(strings.Split(costSheet, "- Cost Sheet")[0])
+		log.Println("Cost Sheet Parent Folder: ", costSheetParentFolder)
+		for _, folder := range folders {
+			if folder.Name == costSheetParentFolder {
+				log.Println("Folder Found: ", folder.Name)
+				foldersToParse = append(foldersToParse, folder)
+				continue OUTER
+			}
+		}
+		log.Println("Folder Not Found: ", costSheetParentFolder)
+
+	}
+	jobs, results := modules.SetupWorkers(10, 20)
+	log.Println("Jobs and Results created successfully")
+
+	go func() {
+		for _, folder := range foldersToParse {
+			jobs <- folder.Id
+		}
+		close(jobs)
+	}()
+
+	var costSheetToSubmit []modules.FileDetails
+	for result := range results {
+		log.Println("Result: ", result)
+		for _, file := range result.FileDetails {
+			if !strings.Contains(file.Name, "Cost") {
+				continue
+			}
+			log.Println("Cost Sheet Found: ", file.Name)
+			costSheetToSubmit = append(costSheetToSubmit, file)
+		}
+	}
+
+	log.Println("All Jobs Completed")
+	log.Println("Cost Sheets to Submit: ", len(costSheetToSubmit))
+	var retryCount = 0
+	const maxRetries = 3
+	// get the cost sheet data
+	for i := 0; i < len(costSheetToSubmit); i++ {
+		costSheet := costSheetToSubmit[i]
+		log.Println("Parsing Cost Sheet: ", costSheet.Name)
+		costSheetData, err := sheetsService.
+			Spreadsheets.Values.
+			Get(costSheet.Id, "Offer Template!A:P").
+			Do()
+		if err != nil {
+			log.Println("Error getting cost sheet data")
+			if retryCount < maxRetries {
+				i--
+				retryCount++
+				time.Sleep(time.Duration(costOverridePause*retryCount) * time.Second)
+				log.Printf("Retrying After a %d timeout ... \n", costOverridePause*retryCount)
+				continue
+			}
+			log.Println(err)
+			return exitFailed
+		}
+		// skip the header row and rows with no data
+		log.Println("Filtering out empty rows")
+		var rows [][]interface{}
+		if len(costSheetData.Values) > 0 {
+			rows = nonEmptyRows(costSheetData.Values[1:])
+		}
+		log.Println("Filtering complete.")
+		if len(rows) == 0 {
+			log.Println("No data found in the cost sheet: ", costSheet.Name)
+			continue
+		}
+
+		err = sendToSkuVault(itemsFromCostSheet(rows))
+		if err != nil {
+			log.Println(err)
+			return exitFailed
+		}
+
+		countDownTimer(costOverridePause)
+	}
+	return exitOK
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/modules"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func getLink(id string) string {
+	return fmt.Sprintf("https://docs.google.com/spreadsheets/d/%s/edit", id)
+}
+
+// parsedCostSheets returns the names of the parsed cost sheets in
+// ./json/parsedFiles.json without duplicates.
+func parsedCostSheets() []string {
+	var p models.ParsedDrivesJson
+	p.GetDrives()
+	log.Println("Removing Non - Cost Sheets")
+
+	for i := 0; i < len(p.Drives); i++ {
+		if !strings.Contains(p.Drives[i], "Cost") {
+			p.Drives = append(p.Drives[:i], p.Drives[i+1:]...)
+			i--
+		}
+	}
+	log.Println("Cost Sheets: ", len(p.Drives))
+	// remove duplicates
+	for i := 0; i < len(p.Drives); i++ {
+		for j := i + 1; j < len(p.Drives); j++ {
+			if p.Drives[i] == p.Drives[j] {
+				p.Drives = append(p.Drives[:j], p.Drives[j+1:]...)
+				j--
+			}
+		}
+	}
+
+	log.Println("Drives Acquired after de dupe: ", len(p.Drives))
+	return p.Drives
+}
+
+// exportCommand writes the PO number, SKU and cost of every row of the parsed
+// cost sheets to a CSV file.
+func exportCommand(args []string) int {
+	flags := commandFlags("export")
+	out := flags.String("out", "./export/cost_export.csv", "path of the CSV file to write")
+	err := flags.Parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	parsedDrives := parsedCostSheets()
+	err = loadConfig()
+	if err == nil {
+		err = setupGoogle()
+	}
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+
+	log.Println("Init Complete. Starting Costing Sheet Sku Export to CSV...")
+	fileList, err := getFolders()
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+
+	log.Println("Files Length: ", len(fileList))
+	jobs, results := modules.SetupWorkers(10, 20)
+	log.Println("Jobs and Results created successfully")
+
+	go func() {
+		for _, file := range fileList {
+			slices := strings.Split(file.Name, "-")
+			if len(slices) > 2 {
+				log.Println("File Name: ", file.Name)
+				jobs <- file.Id
+			} else {
+				continue
+			}
+		}
+		close(jobs)
+		log.Println("Jobs channel closed")
+	}()
+	log.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+
+	var costSheetsToParse []modules.FileDetails
+
+	for driveFile := range results {
+		for _, file := range driveFile.FileDetails {
+			if !strings.Contains(file.Name, "Cost") {
+				continue
+			}
+			log.Println("Cost Sheet Found: ", file.Name)
+			for _, parsedFile := range parsedDrives {
+				if strings.Contains(parsedFile, file.Name) {
+					log.Println("Match Found: ", file.Name)
+					log.Println("File ID: ", file.Id)
+					log.Println("Parent Folder ID: ", driveFile.ParentFolderId)
+					log.Println("File Count: ", driveFile.FileIdsCount)
+					costSheetsToParse = append(costSheetsToParse, file)
+				}
+			}
+		}
+	}
+	log.Println("Cost Sheets to Parse: ", len(costSheetsToParse))
+	csv := "po_number,sku,cost,link\n"
+	var retryCount = 0
+	const maxRetries = 3
+	// get the cost sheet data
+	for i := 0; i < len(costSheetsToParse); i++ {
+		costSheet := costSheetsToParse[i]
+		log.Println("Parsing Cost Sheet: ", costSheet.Name)
+		costSheetData, err := sheetsService.
+			Spreadsheets.Values.
+			Get(costSheet.Id, "Offer Template!J:P").
+			Do()
+		if err != nil {
+			log.Println("Error getting cost sheet data")
+			if retryCount < maxRetries {
+				i--
+				retryCount++
+				time.Sleep(time.Duration(timeout*retryCount) * time.Second)
+				log.Printf("Retrying After a %d timeout ... \n", timeout*retryCount)
+				continue
+			}
+			log.Println(err)
+			return exitFailed
+		}
+		// filter out rows with no data, then remove the header row
+		rows := nonEmptyRows(costSheetData.Values)
+		if len(rows) > 0 {
+			rows = rows[1:]
+		}
+		log.Printf("Found %d rows \n ", len(rows))
+		poNumber := costSheet.Name
+		link := getLink(costSheet.Id)
+		for _, row := range rows {
+			if len(row) < 7 {
+				continue
+			}
+			sku := row[1].(string)
+			cost := row[6].(string)
+			csv += fmt.Sprintf("%s,%s,%s,%s\n", poNumber, sku, cost, link)
+		}
+		// We need columns J - P
+		// CSV columns: po_number, sku, cost
+		// PO comes from the sheet title
+		// Sku comes from column J
+		// Cost comes from column P
+
+	}
+	// write the csv to a file
+	err = os.MkdirAll(filepath.Dir(*out), 0755)
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	outfile, err := os.Create(*out)
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	defer outfile.Close()
+
+	_, err = outfile.WriteString(csv)
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	log.Println("CSV written successfully")
+	log.Println("Program finished")
+	return exitOK
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/surprice/surpricetest"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Runs the Surprice stand-in so the sweep can be pointed at it with
+// BASE_URL=http://localhost:8081.
+//
+// A script file maps sheet URLs to the responses to give, in order:
+//
+//	{"https://docs.google.com/spreadsheets/d/abc/edit#gid=0": ["upstream-502", "po-created"]}
+//
+// Each response is a bundled fixture name or the path of a .json file in the
+// fixture format, such as a response captured from the real server.
+
+var addr = flag.String("addr", ":8081", "address to listen on")
+var defaultResponse = flag.String("default", surpricetest.FixturePoCreated, "fixture or .json file answering uploads without a script")
+var scriptPath = flag.String("script", "", "json file of scripted responses by sheet URL")
+
+func loadResponse(name string) (surpricetest.Response, error) {
+	if strings.HasSuffix(name, ".json") {
+		return surpricetest.LoadResponse(name)
+	}
+	return surpricetest.Fixture(name)
+}
+
+func loadScript(fake *surpricetest.Fake, filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	var script map[string][]string
+	err = json.Unmarshal(data, &script)
+	if err != nil {
+		return err
+	}
+	for sheetUrl, names := range script {
+		for _, name := range names {
+			response, err := loadResponse(name)
+			if err != nil {
+				return err
+			}
+			fake.Script(sheetUrl, response)
+		}
+	}
+	return nil
+}
+
+// logCalls prints each request as it comes in.
+func logCalls(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Println(r.Method, r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func main() {
+	flag.Parse()
+	fake := surpricetest.New()
+	response, err := loadResponse(*defaultResponse)
+	if err != nil {
+		fmt.Println("Error loading default response")
+		panic(err)
+	}
+	fake.SetDefault(response)
+	if *scriptPath != "" {
+		err = loadScript(fake, *scriptPath)
+		if err != nil {
+			fmt.Println("Error loading script")
+			panic(err)
+		}
+	}
+	fmt.Println("Fixtures: ", strings.Join(surpricetest.Fixtures(), ", "))
+	fmt.Println("Fake Surprice listening on ", *addr)
+	err = http.ListenAndServe(*addr, logCalls(fake))
+	if err != nil {
+		panic(err)
+	}
+}
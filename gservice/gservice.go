@@ -0,0 +1,50 @@
+package gservice
+
+import (
+	"context"
+	"github.com/mwalkersigma/drive-parser/metrics"
+	"github.com/mwalkersigma/drive-parser/ratelimit"
+	"github.com/mwalkersigma/drive-parser/tracing"
+	drive "google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+	sheets "google.golang.org/api/sheets/v4"
+	htransport "google.golang.org/api/transport/http"
+	"net/http"
+)
+
+// DriveLimiter and SheetsLimiter are shared by every service created here so
+// all goroutines in the process stay under one per-API quota. They start
+// unlimited; callers set the rate once the config is loaded.
+var DriveLimiter = ratelimit.NewPerMinute(0)
+var SheetsLimiter = ratelimit.NewPerMinute(0)
+
+// newClient returns a client for api that waits on limiter, counts its calls
+// in the metrics and traces each call. The library's own telemetry is turned
+// off so calls are not traced twice.
+func newClient(ctx context.Context, api string, credentialsFile string, limiter *ratelimit.Limiter, scopes ...string) (*http.Client, error) {
+	client, _, err := htransport.NewClient(ctx, option.WithCredentialsFile(credentialsFile), option.WithScopes(scopes...), option.WithTelemetryDisabled())
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = &metrics.Transport{
+		Base: &ratelimit.Transport{Base: tracing.Transport(client.Transport, api), Limiter: limiter},
+		API:  api,
+	}
+	return client, nil
+}
+
+func NewDriveService(ctx context.Context, credentialsFile string) (*drive.Service, error) {
+	client, err := newClient(ctx, "drive", credentialsFile, DriveLimiter, drive.DriveScope)
+	if err != nil {
+		return nil, err
+	}
+	return drive.NewService(ctx, option.WithHTTPClient(client))
+}
+
+func NewSheetsService(ctx context.Context, credentialsFile string) (*sheets.Service, error) {
+	client, err := newClient(ctx, "sheets", credentialsFile, SheetsLimiter, sheets.SpreadsheetsScope, drive.DriveScope)
+	if err != nil {
+		return nil, err
+	}
+	return sheets.NewService(ctx, option.WithHTTPClient(client))
+}
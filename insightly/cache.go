@@ -0,0 +1,233 @@
+package insightly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/models"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchSize is how many opportunities are asked for in one request to the
+// ids endpoint.
+const batchSize = 100
+
+// cachedOpportunity is an opportunity as last fetched. CheckedAt is when its
+// DATE_UPDATED_UTC was last confirmed against Insightly.
+type cachedOpportunity struct {
+	Opportunity models.InsightlyData `json:"opportunity"`
+	CheckedAt   time.Time            `json:"checkedAt"`
+}
+
+type cacheState struct {
+	UsageDate     string                       `json:"usageDate"`
+	UsageCalls    int                          `json:"usageCalls"`
+	Opportunities map[string]cachedOpportunity `json:"opportunities"`
+}
+
+// Cache keeps opportunities on disk between runs along with how many Insightly
+// calls have been made today. An entry is served for TTL after it was last
+// checked; Prefetch checks entries again by their DATE_UPDATED_UTC and only
+// fetches the ones that changed.
+type Cache struct {
+	mu    sync.Mutex
+	path  string
+	TTL   time.Duration
+	state cacheState
+}
+
+func LoadCache(path string, ttl time.Duration) (*Cache, error) {
+	c := &Cache{path: path, TTL: ttl, state: cacheState{Opportunities: map[string]cachedOpportunity{}}}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(file)
+	err = json.NewDecoder(file).Decode(&c.state)
+	if err != nil {
+		return nil, fmt.Errorf("insightly: decoding cache %s: %w", path, err)
+	}
+	if c.state.Opportunities == nil {
+		c.state.Opportunities = map[string]cachedOpportunity{}
+	}
+	return c, nil
+}
+
+func (c *Cache) get(opportunityId string) (*models.InsightlyData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.state.Opportunities[opportunityId]
+	if !ok || time.Since(entry.CheckedAt) > c.TTL {
+		return nil, false
+	}
+	opportunity := entry.Opportunity
+	return &opportunity, true
+}
+
+func (c *Cache) put(opportunity models.InsightlyData, checkedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := strconv.Itoa(opportunity.OpportunityId)
+	c.state.Opportunities[id] = cachedOpportunity{Opportunity: opportunity, CheckedAt: checkedAt}
+}
+
+// stale splits ids into those not cached or not checked within TTL, and
+// returns the cached DATE_UPDATED_UTC of the ones that are cached.
+func (c *Cache) stale(ids []string, now time.Time) ([]string, map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var stale []string
+	updated := map[string]string{}
+	for _, id := range ids {
+		entry, ok := c.state.Opportunities[id]
+		if ok && now.Sub(entry.CheckedAt) <= c.TTL {
+			continue
+		}
+		stale = append(stale, id)
+		if ok {
+			updated[id] = entry.Opportunity.DateUpdatedUtc
+		}
+	}
+	return stale, updated
+}
+
+// confirm marks the cached copy of an opportunity as still current.
+func (c *Cache) confirm(opportunityId string, checkedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.state.Opportunities[opportunityId]
+	if ok {
+		entry.CheckedAt = checkedAt
+		c.state.Opportunities[opportunityId] = entry
+	}
+}
+
+// Prune drops every cached opportunity not in referenced, returning how many
+// were dropped.
+func (c *Cache) Prune(referenced map[string]bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pruned := 0
+	for id := range c.state.Opportunities {
+		if !referenced[id] {
+			delete(c.state.Opportunities, id)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// forget drops the cached copy of an opportunity that has just been written to.
+func (c *Cache) forget(opportunityId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.state.Opportunities, opportunityId)
+}
+
+// reserveCall counts a call against today's usage, refusing once limit is
+// reached. A limit of zero or less is unlimited.
+func (c *Cache) reserveCall(limit int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	today := time.Now().UTC().Format("2006-01-02")
+	if c.state.UsageDate != today {
+		c.state.UsageDate = today
+		c.state.UsageCalls = 0
+	}
+	if limit > 0 && c.state.UsageCalls >= limit {
+		return fmt.Errorf("%w: daily limit of %d calls reached", ErrRateLimited, limit)
+	}
+	c.state.UsageCalls++
+	return nil
+}
+
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tmpPath := c.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(file).Encode(c.state)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, c.path)
+}
+
+// Prefetch brings the cached copies of the opportunities in ids up to date
+// with a few batched calls, so the sweep does not make one call per folder.
+// Entries checked within TTL are left alone. For the rest a brief listing
+// gives their DATE_UPDATED_UTC, and only those that are new or have changed
+// are fetched in full. Opportunities Insightly does not return are left out
+// of the cache so a lookup of them still gets a confirmed 404.
+func (c *Client) Prefetch(ctx context.Context, ids []string) (int, error) {
+	if c.Cache == nil || len(ids) == 0 {
+		return 0, nil
+	}
+	checkedAt := time.Now()
+	stale, cachedUpdated := c.Cache.stale(ids, checkedAt)
+	var changed []string
+	for _, batch := range batches(stale) {
+		var page []models.InsightlyData
+		err := c.listByIds(ctx, batch, true, &page)
+		if err != nil {
+			return 0, err
+		}
+		for _, opportunity := range page {
+			id := strconv.Itoa(opportunity.OpportunityId)
+			updated, ok := cachedUpdated[id]
+			if ok && updated == opportunity.DateUpdatedUtc {
+				c.Cache.confirm(id, checkedAt)
+				continue
+			}
+			changed = append(changed, id)
+		}
+	}
+	fetched := 0
+	for _, batch := range batches(changed) {
+		var page []models.InsightlyData
+		err := c.listByIds(ctx, batch, false, &page)
+		if err != nil {
+			return fetched, err
+		}
+		for _, opportunity := range page {
+			c.Cache.put(opportunity, checkedAt)
+		}
+		fetched += len(page)
+	}
+	return fetched, nil
+}
+
+// listByIds fetches the opportunities in ids in one call, leaving out custom
+// fields and links when brief.
+func (c *Client) listByIds(ctx context.Context, ids []string, brief bool, target *[]models.InsightlyData) error {
+	query := url.Values{}
+	query.Set("ids", strings.Join(ids, ","))
+	query.Set("brief", strconv.FormatBool(brief))
+	query.Set("top", strconv.Itoa(len(ids)))
+	return c.get(ctx, "/Opportunities?"+query.Encode(), target)
+}
+
+func batches(ids []string) [][]string {
+	var batched [][]string
+	for start := 0; start < len(ids); start += batchSize {
+		batched = append(batched, ids[start:min(start+batchSize, len(ids))])
+	}
+	return batched
+}
@@ -0,0 +1,119 @@
+package insightly_test
+
+import (
+	"context"
+	"errors"
+	"github.com/mwalkersigma/drive-parser/insightly"
+	"github.com/mwalkersigma/drive-parser/insightly/insightlytest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func countRequests(server *insightlytest.Server, prefix string) int {
+	count := 0
+	for _, request := range server.Requests() {
+		if strings.HasPrefix(request, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestPrefetch(t *testing.T) {
+	server := insightlytest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	cache, err := insightly.LoadCache(filepath.Join(t.TempDir(), "cache.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cache = cache
+	ctx := context.Background()
+	ids := []string{insightlytest.OpenOpportunityId, insightlytest.WonOpportunityId, insightlytest.MissingOpportunityId}
+
+	fetched, err := client.Prefetch(ctx, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched != 2 {
+		t.Errorf("first Prefetch fetched %d, want 2", fetched)
+	}
+	if calls := countRequests(server, "GET /Opportunities?"); calls != 2 {
+		t.Errorf("first Prefetch made %d calls, want one brief and one full", calls)
+	}
+	before := len(server.Requests())
+	_, err = client.GetOpportunity(ctx, insightlytest.OpenOpportunityId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Requests()) != before {
+		t.Error("GetOpportunity called Insightly for a prefetched opportunity")
+	}
+	_, err = client.GetOpportunity(ctx, insightlytest.MissingOpportunityId)
+	if !errors.Is(err, insightly.ErrNotFound) {
+		t.Errorf("GetOpportunity of a missing opportunity = %v, want ErrNotFound", err)
+	}
+
+	// Within TTL nothing is checked again.
+	before = len(server.Requests())
+	fetched, err = client.Prefetch(ctx, ids[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched != 0 || len(server.Requests()) != before {
+		t.Errorf("Prefetch within TTL fetched %d with %d calls", fetched, len(server.Requests())-before)
+	}
+
+	// Past TTL only the opportunity whose DATE_UPDATED_UTC moved is fetched.
+	cache.TTL = 0
+	won, _ := server.Opportunity(insightlytest.WonOpportunityId)
+	won.OpportunityState = "LOST"
+	won.DateUpdatedUtc = time.Now().Add(time.Hour).UTC().Format("2006-01-02 15:04:05")
+	server.SetOpportunity(insightlytest.WonOpportunityId, won)
+	fetched, err = client.Prefetch(ctx, ids[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched != 1 {
+		t.Errorf("Prefetch after an update fetched %d, want 1", fetched)
+	}
+	cache.TTL = time.Hour
+	opportunity, err := client.GetOpportunity(ctx, insightlytest.WonOpportunityId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opportunity.OpportunityState != "LOST" {
+		t.Errorf("cached state = %s, want the updated LOST", opportunity.OpportunityState)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	server := insightlytest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	cache, err := insightly.LoadCache(filepath.Join(t.TempDir(), "cache.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cache = cache
+	ctx := context.Background()
+	_, err = client.Prefetch(ctx, []string{insightlytest.OpenOpportunityId, insightlytest.WonOpportunityId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pruned := cache.Prune(map[string]bool{insightlytest.OpenOpportunityId: true})
+	if pruned != 1 {
+		t.Errorf("Prune dropped %d, want 1", pruned)
+	}
+	before := len(server.Requests())
+	_, err = client.GetOpportunity(ctx, insightlytest.WonOpportunityId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Requests()) != before+1 {
+		t.Error("GetOpportunity served a pruned opportunity from the cache")
+	}
+}
@@ -0,0 +1,48 @@
+package insightly
+
+import (
+	"context"
+	"errors"
+	"github.com/mwalkersigma/drive-parser/models"
+	"log"
+)
+
+type Decision struct {
+	Action models.OpportunityAction
+	// Rule is the rule that decided Action. Matched is false when no rule
+	// applied and Action fell back to models.ActionNone.
+	Rule    models.OpportunityRule
+	Matched bool
+	// Opportunity is nil when the opportunity does not exist.
+	Opportunity *models.InsightlyData
+}
+
+// Decide looks up the opportunity and picks the action for its folder from
+// rules. Only a confirmed 404 is matched as models.StateNotFound; any other
+// lookup failure is returned so the folder is left alone. The owner is only
+// looked up for the actions that mark the sheet, and failing to find them
+// leaves the owner empty rather than failing the decision.
+func (c *Client) Decide(ctx context.Context, opportunityId string, rules []models.OpportunityRule) (Decision, error) {
+	opportunity, err := c.GetOpportunity(ctx, opportunityId)
+	if errors.Is(err, ErrNotFound) {
+		opportunity, err = nil, nil
+	}
+	if err != nil {
+		return Decision{}, err
+	}
+	if opportunity != nil {
+		err = c.ResolvePipeline(ctx, opportunity)
+		if err != nil {
+			return Decision{}, err
+		}
+	}
+	rule, matched := models.MatchOpportunityRule(rules, opportunity)
+	if opportunity != nil && (rule.Action == models.ActionMarkSuspended || rule.Action == models.ActionMarkForgotten) {
+		err = c.ResolveOwner(ctx, opportunity)
+		if err != nil {
+			log.Printf("Error resolving the owner of opportunity %s\n", opportunityId)
+			log.Println(err)
+		}
+	}
+	return Decision{Action: rule.Action, Rule: rule, Matched: matched, Opportunity: opportunity}, nil
+}
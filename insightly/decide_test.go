@@ -0,0 +1,143 @@
+package insightly_test
+
+import (
+	"context"
+	"errors"
+	"github.com/mwalkersigma/drive-parser/insightly"
+	"github.com/mwalkersigma/drive-parser/insightly/insightlytest"
+	"github.com/mwalkersigma/drive-parser/models"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestDecideFixtureStates(t *testing.T) {
+	server := insightlytest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	rules := models.DefaultOpportunityRules()
+
+	tests := []struct {
+		id        string
+		action    models.OpportunityAction
+		withOwner bool
+	}{
+		{insightlytest.OpenOpportunityId, models.ActionMarkForgotten, true},
+		{insightlytest.WonOpportunityId, models.ActionMoveToWins, false},
+		{insightlytest.LostOpportunityId, models.ActionMoveToLosses, false},
+		{insightlytest.AbandonedOpportunityId, models.ActionMoveToLosses, false},
+		{insightlytest.SuspendedOpportunityId, models.ActionMarkSuspended, true},
+		{insightlytest.MissingOpportunityId, models.ActionMoveToLosses, false},
+	}
+	for _, test := range tests {
+		decision, err := client.Decide(context.Background(), test.id, rules)
+		if err != nil {
+			t.Fatalf("Decide(%s): %v", test.id, err)
+		}
+		if decision.Action != test.action || !decision.Matched {
+			t.Errorf("Decide(%s) = %q (matched %v), want %q", test.id, decision.Action, decision.Matched, test.action)
+		}
+		if test.id == insightlytest.MissingOpportunityId {
+			if decision.Opportunity != nil {
+				t.Errorf("Decide(%s) returned an opportunity for a 404", test.id)
+			}
+			continue
+		}
+		if decision.Opportunity == nil {
+			t.Fatalf("Decide(%s) returned no opportunity", test.id)
+		}
+		if decision.Opportunity.PipelineName != insightlytest.PipelineName || decision.Opportunity.StageName != insightlytest.StageName {
+			t.Errorf("Decide(%s) resolved pipeline %q stage %q", test.id, decision.Opportunity.PipelineName, decision.Opportunity.StageName)
+		}
+		if test.withOwner && decision.Opportunity.Owner.Email != insightlytest.OwnerEmail {
+			t.Errorf("Decide(%s) owner = %+v, want %s", test.id, decision.Opportunity.Owner, insightlytest.OwnerEmail)
+		}
+	}
+}
+
+// Only a confirmed 404 may move a folder to losses; any other failure has to
+// leave the folder alone.
+func TestDecideFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		apiKey string
+		want   error
+	}{
+		{"unauthorized", http.StatusUnauthorized, "not-the-key", insightly.ErrUnauthorized},
+		{"rate limited", http.StatusTooManyRequests, insightlytest.APIKey, insightly.ErrRateLimited},
+		{"server error", http.StatusInternalServerError, insightlytest.APIKey, insightly.ErrServer},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := insightlytest.NewServer()
+			defer server.Close()
+			client := server.Client()
+			client.APIKey = test.apiKey
+			client.MaxRetries = 0
+			server.Fail("/Opportunities/"+insightlytest.LostOpportunityId, test.status, -1)
+
+			decision, err := client.Decide(context.Background(), insightlytest.LostOpportunityId, models.DefaultOpportunityRules())
+			if !errors.Is(err, test.want) {
+				t.Fatalf("Decide error = %v, want %v", err, test.want)
+			}
+			if decision.Action == models.ActionMoveToLosses {
+				t.Errorf("Decide moved the folder to losses after a %d", test.status)
+			}
+		})
+	}
+}
+
+func TestDecideRetriesRateLimit(t *testing.T) {
+	server := insightlytest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	client.MaxRetries = 1
+	server.Fail("/Opportunities/"+insightlytest.WonOpportunityId, http.StatusTooManyRequests, 1)
+
+	decision, err := client.Decide(context.Background(), insightlytest.WonOpportunityId, models.DefaultOpportunityRules())
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if decision.Action != models.ActionMoveToWins {
+		t.Errorf("Decide = %q, want %q", decision.Action, models.ActionMoveToWins)
+	}
+}
+
+// A failed owner lookup must not hold up any decision, and moves never look
+// the owner up at all.
+func TestDecideOwnerLookupFails(t *testing.T) {
+	server := insightlytest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	client.MaxRetries = 0
+	server.Fail("/Users/"+strconv.Itoa(insightlytest.OwnerUserId), http.StatusInternalServerError, -1)
+	rules := models.DefaultOpportunityRules()
+
+	tests := []struct {
+		id          string
+		action      models.OpportunityAction
+		ownerLookup bool
+	}{
+		{insightlytest.WonOpportunityId, models.ActionMoveToWins, false},
+		{insightlytest.LostOpportunityId, models.ActionMoveToLosses, false},
+		{insightlytest.SuspendedOpportunityId, models.ActionMarkSuspended, true},
+		{insightlytest.OpenOpportunityId, models.ActionMarkForgotten, true},
+	}
+	for _, test := range tests {
+		before := countRequests(server, "GET /Users/")
+		decision, err := client.Decide(context.Background(), test.id, rules)
+		if err != nil {
+			t.Fatalf("Decide(%s): %v", test.id, err)
+		}
+		if decision.Action != test.action {
+			t.Errorf("Decide(%s) = %q, want %q", test.id, decision.Action, test.action)
+		}
+		if decision.Opportunity.Owner != (models.OpportunityOwner{}) {
+			t.Errorf("Decide(%s) owner = %+v, want empty", test.id, decision.Opportunity.Owner)
+		}
+		if looked := countRequests(server, "GET /Users/") > before; looked != test.ownerLookup {
+			t.Errorf("Decide(%s) looked up the owner: %v, want %v", test.id, looked, test.ownerLookup)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package insightly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/ratelimit"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultBaseURL = "https://api.insightly.com/v3.1"
+
+// Errors returned by the Client, wrapped with the request that caused them.
+// Callers should check them with errors.Is.
+var (
+	ErrNotFound     = errors.New("insightly: not found")
+	ErrUnauthorized = errors.New("insightly: unauthorized")
+	ErrRateLimited  = errors.New("insightly: rate limited")
+	ErrServer       = errors.New("insightly: server error")
+)
+
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+	// MaxRetries is how many times a 429 response is retried before giving
+	// up with ErrRateLimited.
+	MaxRetries int
+	// Limiter paces requests to stay under Insightly's per-second limit.
+	Limiter *ratelimit.Limiter
+	// DailyLimit caps calls per UTC day, counted in Cache so it holds across
+	// runs. Zero is unlimited, as is any limit when Cache is nil.
+	DailyLimit int
+	// Cache, when set, serves opportunities fetched recently enough.
+	Cache *Cache
+
+	pipelines pipelines
+	users     users
+}
+
+// NewClient returns a Client using INSIGHTLY_API_KEY. The API is reached at
+// INSIGHTLY_BASE_URL when set, otherwise at DefaultBaseURL.
+func NewClient() *Client {
+	baseURL := os.Getenv("INSIGHTLY_BASE_URL")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		APIKey:     os.Getenv("INSIGHTLY_API_KEY"),
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+		MaxRetries: 3,
+	}
+}
+
+func (c *Client) GetOpportunity(ctx context.Context, opportunityId string) (*models.InsightlyData, error) {
+	if c.Cache != nil {
+		if opportunity, ok := c.Cache.get(opportunityId); ok {
+			return opportunity, nil
+		}
+	}
+	var opportunity models.InsightlyData
+	err := c.get(ctx, "/Opportunities/"+opportunityId, &opportunity)
+	if err != nil {
+		return nil, err
+	}
+	if c.Cache != nil {
+		c.Cache.put(opportunity, time.Now())
+	}
+	return &opportunity, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, target interface{}) error {
+	return c.do(ctx, http.MethodGet, path, nil, target)
+}
+
+// do sends body, when not nil, as JSON and decodes the response into target,
+// when not nil. Only 429s are retried; Insightly has not acted on those so
+// retrying a write is safe.
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, target interface{}) error {
+	if c.APIKey == "" {
+		return fmt.Errorf("%w: no API key found", ErrUnauthorized)
+	}
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		if c.Cache != nil {
+			err := c.Cache.reserveCall(c.DailyLimit)
+			if err != nil {
+				return err
+			}
+		}
+		err := c.Limiter.Wait(ctx)
+		if err != nil {
+			return err
+		}
+		var reqBody io.Reader
+		if payload != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reqBody)
+		if err != nil {
+			return err
+		}
+		req.Header.Add("Authorization", "Basic "+c.APIKey)
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < c.MaxRetries {
+			wait := retryAfter(resp, attempt)
+			closeBody(resp.Body)
+			log.Printf("Insightly rate limit reached. Retrying in %s\n", wait)
+			select {
+			case <-time.After(wait):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		err = decodeResponse(resp, method+" "+path, target)
+		closeBody(resp.Body)
+		return err
+	}
+}
+
+func decodeResponse(resp *http.Response, request string, target interface{}) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, request)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %s returned %s", ErrUnauthorized, request, resp.Status)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", ErrRateLimited, request)
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("%w: %s returned %s", ErrServer, request, resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("insightly: %s returned %s", request, resp.Status)
+	}
+	if target == nil {
+		return nil
+	}
+	err := json.NewDecoder(resp.Body).Decode(target)
+	if err != nil {
+		return fmt.Errorf("insightly: decoding %s: %w", request, err)
+	}
+	return nil
+}
+
+// retryAfter honours the Retry-After header and otherwise backs off
+// exponentially from one second.
+func retryAfter(resp *http.Response, attempt int) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return time.Second << attempt
+}
+
+func closeBody(body io.ReadCloser) {
+	err := body.Close()
+	if err != nil {
+		log.Println("Error closing body")
+	}
+}
@@ -0,0 +1,301 @@
+// Package insightlytest serves a stand-in for the Insightly API so code that
+// talks to Insightly can run offline, from tests or by hand.
+package insightlytest
+
+import (
+	"encoding/json"
+	"github.com/mwalkersigma/drive-parser/insightly"
+	"github.com/mwalkersigma/drive-parser/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Opportunity IDs served by NewServer, one per state the sweep acts on.
+// MissingOpportunityId is never served and always answers 404.
+const (
+	OpenOpportunityId      = "1001"
+	WonOpportunityId       = "1002"
+	LostOpportunityId      = "1003"
+	AbandonedOpportunityId = "1004"
+	SuspendedOpportunityId = "1005"
+	MissingOpportunityId   = "1999"
+)
+
+// Every fixture opportunity sits in this pipeline and stage.
+const (
+	PipelineId   = 1055391
+	PipelineName = "Surplus Procurement"
+	StageId      = 4380676
+	StageName    = "Awaiting PO"
+)
+
+// Every fixture opportunity is owned by this user.
+const (
+	OwnerUserId    = 2001
+	OwnerFirstName = "Pat"
+	OwnerLastName  = "Buyer"
+	OwnerEmail     = "pat.buyer@example.com"
+)
+
+const APIKey = "insightlytest"
+
+type Server struct {
+	*httptest.Server
+	mu            sync.Mutex
+	opportunities map[string]models.InsightlyData
+	notes         map[string][]models.InsightlyNote
+	requests      []string
+	failures      map[string]*failure
+}
+
+type failure struct {
+	status int
+	times  int
+}
+
+// NewServer starts a stand-in serving the fixture opportunities. Close it when
+// done.
+func NewServer() *Server {
+	s := &Server{opportunities: map[string]models.InsightlyData{}, notes: map[string][]models.InsightlyNote{}, failures: map[string]*failure{}}
+	fixtures := map[string]string{
+		OpenOpportunityId:      "OPEN",
+		WonOpportunityId:       "WON",
+		LostOpportunityId:      "LOST",
+		AbandonedOpportunityId: "ABANDONED",
+		SuspendedOpportunityId: "SUSPENDED",
+	}
+	for id, state := range fixtures {
+		s.opportunities[id] = fixture(id, state)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /Opportunities", s.listOpportunities)
+	mux.HandleFunc("GET /Opportunities/{id}", s.getOpportunity)
+	mux.HandleFunc("POST /Opportunities/{id}/Notes", s.addNote)
+	mux.HandleFunc("PUT /Opportunities", s.updateOpportunity)
+	mux.HandleFunc("PUT /Opportunities/{id}/PipelineStage", s.setStage)
+	mux.HandleFunc("GET /Users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != strconv.Itoa(OwnerUserId) {
+			http.Error(w, `{"Message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, models.InsightlyUser{UserId: OwnerUserId, FirstName: OwnerFirstName, LastName: OwnerLastName, EmailAddress: OwnerEmail})
+	})
+	mux.HandleFunc("GET /Pipelines", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []models.InsightlyPipeline{{PipelineId: PipelineId, PipelineName: PipelineName}})
+	})
+	mux.HandleFunc("GET /PipelineStages", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []models.InsightlyPipelineStage{{StageId: StageId, PipelineId: PipelineId, StageName: StageName, StageOrder: 1}})
+	})
+	s.Server = httptest.NewServer(s.authorized(mux))
+	return s
+}
+
+func fixture(id string, state string) models.InsightlyData {
+	opportunityId, _ := strconv.Atoi(id)
+	return models.InsightlyData{
+		OpportunityId:     opportunityId,
+		OpportunityName:   "Fixture " + strings.ToLower(state) + " opportunity",
+		OpportunityState:  state,
+		ResponsibleUserId: OwnerUserId,
+		PipelineId:        PipelineId,
+		StageId:           StageId,
+		DateCreatedUtc:    "2024-01-01 00:00:00",
+		DateUpdatedUtc:    time.Now().UTC().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// Client returns an insightly.Client pointed at the stand-in.
+func (s *Server) Client() *insightly.Client {
+	client := insightly.NewClient()
+	client.BaseURL = s.URL
+	client.APIKey = APIKey
+	client.HTTPClient = s.Server.Client()
+	return client
+}
+
+// SetOpportunity adds or replaces the opportunity served under id.
+func (s *Server) SetOpportunity(id string, opportunity models.InsightlyData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opportunities[id] = opportunity
+}
+
+// Notes returns the notes added to the opportunity served under id.
+func (s *Server) Notes(id string) []models.InsightlyNote {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.InsightlyNote(nil), s.notes[id]...)
+}
+
+// Opportunity returns the opportunity served under id as it stands now.
+func (s *Server) Opportunity(id string) (models.InsightlyData, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	opportunity, ok := s.opportunities[id]
+	return opportunity, ok
+}
+
+// Requests returns the method and path of every request received so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Fail answers the next times requests for path, such as
+// "/Opportunities/1001", with status instead of serving them. A negative
+// times fails every request.
+func (s *Server) Fail(path string, status int, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = &failure{status: status, times: times}
+}
+
+func (s *Server) authorized(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		status := 0
+		if fail, ok := s.failures[r.URL.Path]; ok && fail.times != 0 {
+			status = fail.status
+			fail.times--
+		}
+		s.mu.Unlock()
+		if r.Header.Get("Authorization") != "Basic "+APIKey {
+			http.Error(w, `{"Message":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		if status != 0 {
+			http.Error(w, `{"Message":"`+http.StatusText(status)+`"}`, status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) getOpportunity(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	opportunity, ok := s.opportunities[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, `{"Message":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, opportunity)
+}
+
+func (s *Server) addNote(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var note models.InsightlyNote
+	err := json.NewDecoder(r.Body).Decode(&note)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	_, ok := s.opportunities[id]
+	if ok {
+		note.NoteId = len(s.notes[id]) + 1
+		s.notes[id] = append(s.notes[id], note)
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, `{"Message":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, note)
+}
+
+// updateOpportunity only applies the custom fields sent, which is all the
+// sweep ever updates this way.
+func (s *Server) updateOpportunity(w http.ResponseWriter, r *http.Request) {
+	var update models.InsightlyData
+	err := json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := strconv.Itoa(update.OpportunityId)
+	s.mu.Lock()
+	opportunity, ok := s.opportunities[id]
+	if ok {
+		for _, field := range update.CustomFields {
+			opportunity.CustomFields = setCustomField(opportunity.CustomFields, field)
+		}
+		opportunity.DateUpdatedUtc = time.Now().UTC().Format("2006-01-02 15:04:05")
+		s.opportunities[id] = opportunity
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, `{"Message":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, opportunity)
+}
+
+func setCustomField(fields []models.CustomField, field models.CustomField) []models.CustomField {
+	for i := range fields {
+		if fields[i].FieldName == field.FieldName {
+			fields[i].FieldValue = field.FieldValue
+			return fields
+		}
+	}
+	return append(fields, field)
+}
+
+func (s *Server) setStage(w http.ResponseWriter, r *http.Request) {
+	var change struct {
+		StageId int `json:"STAGE_ID"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&change)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	opportunity, ok := s.opportunities[r.PathValue("id")]
+	if ok {
+		opportunity.StageId = change.StageId
+		opportunity.DateUpdatedUtc = time.Now().UTC().Format("2006-01-02 15:04:05")
+		s.opportunities[r.PathValue("id")] = opportunity
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, `{"Message":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, opportunity)
+}
+
+// listOpportunities supports the ids and brief parameters of the real list
+// endpoint. Unknown IDs are left out, as Insightly does.
+func (s *Server) listOpportunities(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	brief := query.Get("brief") == "true"
+	matches := []models.InsightlyData{}
+	s.mu.Lock()
+	for _, id := range strings.Split(query.Get("ids"), ",") {
+		opportunity, ok := s.opportunities[id]
+		if !ok {
+			continue
+		}
+		if brief {
+			opportunity.CustomFields = nil
+		}
+		matches = append(matches, opportunity)
+	}
+	s.mu.Unlock()
+	writeJSON(w, matches)
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
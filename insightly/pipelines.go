@@ -0,0 +1,61 @@
+package insightly
+
+import (
+	"context"
+	"github.com/mwalkersigma/drive-parser/models"
+	"sync"
+)
+
+// pipelines holds the pipeline and stage names, which change rarely enough to
+// be fetched once per process.
+type pipelines struct {
+	mu     sync.Mutex
+	loaded bool
+	names  map[int]string
+	stages map[int]string
+}
+
+func (c *Client) loadPipelines(ctx context.Context) error {
+	c.pipelines.mu.Lock()
+	defer c.pipelines.mu.Unlock()
+	if c.pipelines.loaded {
+		return nil
+	}
+	var pipelineList []models.InsightlyPipeline
+	err := c.get(ctx, "/Pipelines", &pipelineList)
+	if err != nil {
+		return err
+	}
+	var stageList []models.InsightlyPipelineStage
+	err = c.get(ctx, "/PipelineStages", &stageList)
+	if err != nil {
+		return err
+	}
+	c.pipelines.names = map[int]string{}
+	for _, pipeline := range pipelineList {
+		c.pipelines.names[pipeline.PipelineId] = pipeline.PipelineName
+	}
+	c.pipelines.stages = map[int]string{}
+	for _, stage := range stageList {
+		c.pipelines.stages[stage.StageId] = stage.StageName
+	}
+	c.pipelines.loaded = true
+	return nil
+}
+
+// ResolvePipeline fills in the pipeline and stage names of opportunity from
+// its PIPELINE_ID and STAGE_ID.
+func (c *Client) ResolvePipeline(ctx context.Context, opportunity *models.InsightlyData) error {
+	if opportunity.PipelineId == 0 && opportunity.StageId == 0 {
+		return nil
+	}
+	err := c.loadPipelines(ctx)
+	if err != nil {
+		return err
+	}
+	c.pipelines.mu.Lock()
+	defer c.pipelines.mu.Unlock()
+	opportunity.PipelineName = c.pipelines.names[opportunity.PipelineId]
+	opportunity.StageName = c.pipelines.stages[opportunity.StageId]
+	return nil
+}
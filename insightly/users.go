@@ -0,0 +1,57 @@
+package insightly
+
+import (
+	"context"
+	"errors"
+	"github.com/mwalkersigma/drive-parser/models"
+	"strconv"
+	"sync"
+)
+
+// users remembers every user looked up, including the ones that no longer
+// exist, so each owner costs at most one call per process.
+type users struct {
+	mu   sync.Mutex
+	byId map[int]*models.InsightlyUser
+}
+
+// GetUser returns the Insightly user, or nil when there is no such user. The
+// lock is not held during the request, so lookups of different users do not
+// wait on each other.
+func (c *Client) GetUser(ctx context.Context, userId int) (*models.InsightlyUser, error) {
+	c.users.mu.Lock()
+	user, ok := c.users.byId[userId]
+	c.users.mu.Unlock()
+	if ok {
+		return user, nil
+	}
+	var fetched models.InsightlyUser
+	err := c.get(ctx, "/Users/"+strconv.Itoa(userId), &fetched)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		user = &fetched
+	}
+	c.users.mu.Lock()
+	defer c.users.mu.Unlock()
+	if c.users.byId == nil {
+		c.users.byId = map[int]*models.InsightlyUser{}
+	}
+	c.users.byId[userId] = user
+	return user, nil
+}
+
+// ResolveOwner fills in the owner of opportunity from its
+// RESPONSIBLE_USER_ID. An owner who no longer exists is left empty.
+func (c *Client) ResolveOwner(ctx context.Context, opportunity *models.InsightlyData) error {
+	if opportunity.ResponsibleUserId == 0 {
+		return nil
+	}
+	user, err := c.GetUser(ctx, opportunity.ResponsibleUserId)
+	if err != nil || user == nil {
+		return err
+	}
+	opportunity.Owner = models.OpportunityOwner{Name: user.Name(), Email: user.EmailAddress}
+	return nil
+}
@@ -0,0 +1,47 @@
+package insightly
+
+import (
+	"context"
+	"github.com/mwalkersigma/drive-parser/models"
+	"net/http"
+	"strconv"
+)
+
+// AddNote attaches a note to the opportunity.
+func (c *Client) AddNote(ctx context.Context, opportunityId string, title string, body string) error {
+	note := models.InsightlyNote{Title: title, Body: body}
+	err := c.do(ctx, http.MethodPost, "/Opportunities/"+opportunityId+"/Notes", note, nil)
+	return err
+}
+
+// SetCustomField updates a single custom field, leaving the rest of the
+// opportunity as it is.
+func (c *Client) SetCustomField(ctx context.Context, opportunityId string, name string, value string) error {
+	id, err := strconv.Atoi(opportunityId)
+	if err != nil {
+		return err
+	}
+	update := struct {
+		OpportunityId int                  `json:"OPPORTUNITY_ID"`
+		CustomFields  []models.CustomField `json:"CUSTOMFIELDS"`
+	}{id, []models.CustomField{{FieldName: name, FieldValue: value}}}
+	err = c.do(ctx, http.MethodPut, "/Opportunities", update, nil)
+	c.forget(opportunityId)
+	return err
+}
+
+// SetStage advances the opportunity to stageId within its current pipeline.
+func (c *Client) SetStage(ctx context.Context, opportunityId string, stageId int) error {
+	change := struct {
+		StageId int `json:"STAGE_ID"`
+	}{stageId}
+	err := c.do(ctx, http.MethodPut, "/Opportunities/"+opportunityId+"/PipelineStage", change, nil)
+	c.forget(opportunityId)
+	return err
+}
+
+func (c *Client) forget(opportunityId string) {
+	if c.Cache != nil {
+		c.Cache.forget(opportunityId)
+	}
+}
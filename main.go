@@ -2,20 +2,27 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/joho/godotenv"
+	"github.com/mwalkersigma/drive-parser/gservice"
+	"github.com/mwalkersigma/drive-parser/insightly"
+	"github.com/mwalkersigma/drive-parser/metrics"
 	"github.com/mwalkersigma/drive-parser/models"
 	"github.com/mwalkersigma/drive-parser/modules"
+	"github.com/mwalkersigma/drive-parser/ratelimit"
+	"github.com/mwalkersigma/drive-parser/stats"
+	"github.com/mwalkersigma/drive-parser/surprice"
+	"github.com/mwalkersigma/drive-parser/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	drive "google.golang.org/api/drive/v3"
-	"google.golang.org/api/option"
-	_ "google.golang.org/api/sheets/v4"
 	sheets "google.golang.org/api/sheets/v4"
-	"io"
-	"net/http"
-	"os"
+	"log"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -27,60 +34,122 @@ var lossesFolderId string
 var driveService *drive.Service
 var sheetsService *sheets.Service
 
-var surpriceURLUpdateCost, winsFolderName string
+var winsFolderName string
 
 var timeout = 1
-var rateLimitSleep = 0
+
+// agedFolderDays is how old a folder without cost has to be before Insightly
+// is asked whether the opportunity is still alive.
+const agedFolderDays = 60
+
 var config models.ConfigJson
 var start time.Time
-var timeSleepingGettingCost = 0
-var client = &http.Client{Timeout: 60 * time.Second * 5}
+var surpriceClient *surprice.Client
+var driveParserLimiter = ratelimit.NewPerMinute(0)
+var insightlyClient *insightly.Client
+var recorder = stats.NewRecorder()
 
-func countDownTimer(duration int) {
-	for i := duration; i > 0; i-- {
-		// print on the same line
-		fmt.Printf("\rSleeping for %d seconds ", i)
-		time.Sleep(time.Second)
-	}
+// Counters shared by the process workers.
+var callsToDriveParser atomic.Int64
+var posGenerated atomic.Int64
+
+// Flags of the sweep and serve commands.
+var incremental bool
+var metricsAddr string
+var resume bool
+
+func addRunFlags(flags *flag.FlagSet) {
+	flags.BoolVar(&incremental, "incremental", false, "only re-evaluate folders changed since the last run and folders that just became aged")
+	flags.StringVar(&metricsAddr, "metrics-addr", "", "serve Prometheus metrics at this address, e.g. :9090, while the sweep runs")
+	flags.BoolVar(&resume, "resume", false, "skip folders already handled by the last interrupted run")
 }
 
-func CallDriveParser(body string, target interface{}) error {
-	resp, err := client.Post(surpriceURLUpdateCost, "application/json", strings.NewReader(body))
-	if err != nil {
-		fmt.Println("Error calling Drive Parser")
-		return err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+// driveParserRetries is how many more times a sheet is sent after the Drive
+// Parser reports a 502 from upstream, waiting driveParserRetryBackoff and then
+// twice as long before each further try.
+const driveParserRetries = 3
+
+var driveParserRetryBackoff = 2 * time.Second
+
+// retryDriveParser resends the sheet until the Drive Parser gets past the
+// upstream 502 or the retries run out, returning the last response. It only
+// fails when no retry got a response at all or ctx is done while waiting.
+func retryDriveParser(ctx context.Context, logger *log.Logger, sheetUrl string) (*models.DriveParserResponse, error) {
+	var response *models.DriveParserResponse
+	var lastErr error
+	wait := driveParserRetryBackoff
+	for retry := 1; retry <= driveParserRetries; retry++ {
+		logger.Printf("Retry %d of %d in %s\n", retry, driveParserRetries, wait)
+		waitStart := time.Now()
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			recorder.Since(stats.PhaseRetryWait, waitStart, ctx.Err())
+			return nil, ctx.Err()
+		}
+		recorder.Since(stats.PhaseRetryWait, waitStart, nil)
+		wait *= 2
+		callsToDriveParser.Add(1)
+		retried, err := CallDriveParser(ctx, logger, sheetUrl)
 		if err != nil {
-			fmt.Println("Error closing response body")
-			fmt.Println(err)
+			logger.Println("Error calling Drive Parser")
+			logger.Println(err)
+			lastErr = err
+			continue
 		}
-	}(resp.Body)
+		response = retried
+		if response.Result() != models.ResultUpstreamBadGateway {
+			return response, nil
+		}
+	}
+	if response == nil {
+		return nil, lastErr
+	}
+	return response, nil
+}
 
-	return json.NewDecoder(resp.Body).Decode(target)
+func CallDriveParser(ctx context.Context, logger *log.Logger, sheetUrl string) (response *models.DriveParserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "drive parser upload", attribute.String("sheet.url", sheetUrl))
+	defer func() {
+		if response != nil {
+			span.SetAttributes(attribute.String("drive_parser.result", string(response.Result())))
+		}
+		tracing.End(span, err)
+	}()
+	err = driveParserLimiter.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	callStart := time.Now()
+	response, err = surpriceClient.UploadCostSheet(ctx, sheetUrl)
+	recorder.Since(stats.PhaseDriveParser, callStart, err)
+	if err != nil {
+		logger.Println("Error calling Drive Parser")
+		return nil, err
+	}
+	return response, nil
 }
 
 func getFolderId(ds *drive.Service, folderName string) (string, error) {
-	fmt.Println(fmt.Sprintf("Getting %s folder", folderName))
+	log.Println(fmt.Sprintf("Getting %s folder", folderName))
 	var folders []*drive.File
 	files, err := ds.Files.List().Q(fmt.Sprintf("'%s' in parents and mimeType = 'application/vnd.google-apps.folder'", ParentFolderID)).Do()
 	if err != nil {
-		fmt.Println(fmt.Sprintf("Error getting %s folder", folderName))
-		fmt.Println(err)
+		log.Println(fmt.Sprintf("Error getting %s folder", folderName))
+		log.Println(err)
 		return "", err
 	}
 	folders = append(folders, files.Files...)
 	if files.NextPageToken != "" {
 		for files.NextPageToken != "" {
-			fmt.Println("Next page token found")
+			log.Println("Next page token found")
 			files, err = ds.Files.List().
 				Fields("files(id, name), nextPageToken").
 				Q(fmt.Sprintf("'%s' in parents and mimeType = 'application/vnd.google-apps.folder'", ParentFolderID)).
 				PageToken(files.NextPageToken).Do()
 			if err != nil {
-				fmt.Println("Error getting files from folder")
-				panic(err)
+				log.Println("Error getting files from folder")
+				return "", err
 			}
 			folders = append(folders, files.Files...)
 		}
@@ -94,7 +163,11 @@ func getFolderId(ds *drive.Service, folderName string) (string, error) {
 		}
 	}
 
-	fmt.Println(fmt.Sprintf(" %s Not Found.", folderName))
+	log.Println(fmt.Sprintf(" %s Not Found.", folderName))
+	if dryRun {
+		log.Println(fmt.Sprintf("Dry run: not creating %s folder", folderName))
+		return "", nil
+	}
 
 	// if we get here, we didn't find the folder
 	createFileCall, err := ds.Files.Create(&drive.File{
@@ -104,74 +177,149 @@ func getFolderId(ds *drive.Service, folderName string) (string, error) {
 	}).Do()
 
 	if err != nil {
-		fmt.Println(fmt.Sprintf("Error creating %s folder", folderName))
-		fmt.Println(err)
+		log.Println(fmt.Sprintf("Error creating %s folder", folderName))
+		log.Println(err)
 		return "", err
 	}
-	fmt.Println("Folder created successfully")
+	log.Println("Folder created successfully")
 	return createFileCall.Id, nil
 }
 
-func init() {
-	start = time.Now()
+// credentialsFile is the path of a Google service account key in the
+// credentials directory.
+func credentialsFile(name string) string {
+	return filepath.Join(credentialsDir, name)
+}
+
+// loadConfig reads the .env file and the config and applies the rate limits.
+func loadConfig() error {
 	err := godotenv.Load()
 	if err != nil {
-		panic(err)
+		log.Println("Error loading .env")
+		return err
 	}
-	err = config.GetConfig()
+	err = config.GetConfig(configPath)
 	if err != nil {
-		fmt.Println("Error getting config")
-		fmt.Println(err)
-		panic(err)
+		log.Println("Error getting config")
+		return err
 	}
 	timeout = config.SleepTimeOut
+	gservice.DriveLimiter.SetRate(config.RateLimits.DrivePerMinute, time.Minute)
+	gservice.SheetsLimiter.SetRate(config.RateLimits.SheetsPerMinute, time.Minute)
+	driveParserLimiter.SetRate(config.RateLimits.DriveParserPerMinute, time.Minute)
+	return nil
+}
 
-	winsFolderName = fmt.Sprintf("%s Surplus Procurement Wins", time.Now().Format("2006"))
-	fmt.Println("Wins Folder Name: ", winsFolderName)
-
-	lossFolderName := fmt.Sprintf("Surplus Procurement Lost")
-
-	surpriceURLUpdateCost = fmt.Sprintf("%s/api/v1/costSheet/upload", os.Getenv("BASE_URL"))
-	fmt.Println("Surprice URL: ", surpriceURLUpdateCost)
+// setupGoogle creates the Drive and Sheets services and hands them to the
+// modules package along with the Surprice client, if there is one yet.
+func setupGoogle() error {
 	ctx := context.Background()
-	ds, driveErr := drive.NewService(ctx, option.WithCredentialsFile("./cert.json"))
-	if driveErr != nil {
-		fmt.Println("Error creating new service")
-		panic(driveErr)
+	ds, err := gservice.NewDriveService(ctx, credentialsFile("cert.json"))
+	if err != nil {
+		log.Println("Error creating new service")
+		return err
 	}
 	driveService = ds
-
-	ss, sheetsErr := sheets.NewService(ctx, option.WithCredentialsFile("./SheetCert.json"))
-	if sheetsErr != nil {
-		fmt.Println("Error creating new service")
-		panic(sheetsErr)
+	ss, err := gservice.NewSheetsService(ctx, credentialsFile("SheetCert.json"))
+	if err != nil {
+		log.Println("Error creating new service")
+		return err
 	}
 	sheetsService = ss
+	modules.Setup(driveService, surpriceClient)
+	return nil
+}
 
-	winsFolderId, err = getFolderId(driveService, winsFolderName)
-	fmt.Println("Wins Folder ID: ", winsFolderId)
+// setupSweep readies everything a sweep talks to: the config, the Insightly
+// and Surprice clients, Google and the losses folder.
+func setupSweep() error {
+	err := loadConfig()
 	if err != nil {
-		fmt.Println("Error getting wins folder")
-		fmt.Println(err)
-		panic(err)
+		return err
+	}
+	insightlyClient = insightly.NewClient()
+	insightlyClient.Limiter = ratelimit.NewPerSecond(config.Insightly.RequestsPerSecond)
+	insightlyClient.HTTPClient.Transport = &metrics.Transport{Base: tracing.Transport(insightlyClient.HTTPClient.Transport, "insightly"), API: "insightly"}
+	insightlyClient.DailyLimit = config.Insightly.DailyLimit
+	insightlyClient.Cache, err = insightly.LoadCache("./json/insightlyCache.json", time.Duration(config.Insightly.CacheTTLHours)*time.Hour)
+	if err != nil {
+		log.Println("Error loading Insightly cache")
+		return err
 	}
 
-	lossesFolderId, err = getFolderId(driveService, lossFolderName)
-	fmt.Println("Losses Folder ID: ", lossesFolderId)
+	surpriceClient = surprice.NewClient()
+	surpriceClient.HTTPClient.Transport = &metrics.Transport{Base: tracing.Transport(surpriceClient.HTTPClient.Transport, "surprice"), API: "surprice"}
+	log.Println("Surprice URL: ", surpriceClient.BaseURL)
+	err = setupGoogle()
+	if err != nil {
+		return err
+	}
 
+	lossesFolderId, err = getFolderId(driveService, "Surplus Procurement Lost")
 	if err != nil {
-		fmt.Println("Error getting lost folder")
-		fmt.Println(err)
-		panic(err)
+		log.Println("Error getting lost folder")
+		return err
+	}
+	log.Println("Losses Folder ID: ", lossesFolderId)
+	if dryRun {
+		log.Println("Dry run: nothing will be created, moved, marked or sent")
 	}
+	return nil
+}
 
+// startRunServices sets up what every process that runs sweeps shares:
+// abort reporting, tracing and metrics.
+func startRunServices() error {
+	handleSignals()
+	modules.OnWorkerPanic = func(recovered interface{}) {
+		finishRun(false, fmt.Sprint(recovered))
+		shutdownTracing()
+	}
+	err := tracing.Setup(config.Tracing)
+	if err != nil {
+		log.Println("Error setting up tracing")
+		return err
+	}
+	if config.Tracing.Exporter != models.TracingExporterNone {
+		log.Println("Exporting traces with: ", config.Tracing.Exporter)
+	}
+	recorder.OnObserve(func(phase string, d time.Duration, err error) {
+		metrics.PhaseDuration.Observe(d.Seconds(), phase)
+		if err != nil {
+			metrics.Errors.Inc(phase, stats.Categorize(err))
+		}
+	})
+	if metricsAddr != "" {
+		log.Println("Serving metrics at ", metricsAddr+"/metrics")
+		metrics.Serve(metricsAddr)
+	}
+	return nil
 }
 
-func decideSheet(result modules.WorkerResult) (sheetId string, hasCostSheet bool, sheetFound bool, name string) {
+// resolveWinsFolder points winsFolderId at this year's wins folder. It looks
+// the folder up again once the year has turned, so a long running serve does
+// not keep filing wins under last year.
+func resolveWinsFolder() error {
+	name := fmt.Sprintf("%s Surplus Procurement Wins", time.Now().Format("2006"))
+	if name == winsFolderName && winsFolderId != "" {
+		return nil
+	}
+	id, err := getFolderId(driveService, name)
+	if err != nil {
+		return err
+	}
+	winsFolderName = name
+	winsFolderId = id
+	log.Println("Wins Folder Name: ", winsFolderName)
+	log.Println("Wins Folder ID: ", winsFolderId)
+	return nil
+}
+
+func decideSheet(logger *log.Logger, result modules.WorkerResult) (sheetId string, hasCostSheet bool, sheetFound bool, name string) {
 	var resultFileDetails modules.FileDetails
 	for _, fileDetails := range result.FileDetails {
 		if strings.Contains(fileDetails.Name, "Cost Sheet") {
-			fmt.Println("Cost Sheet Found : ", fileDetails.Name)
+			logger.Println("Cost Sheet Found : ", fileDetails.Name)
 			return fileDetails.Id, true, true, fileDetails.Name
 		}
 
@@ -185,7 +333,7 @@ func decideSheet(result modules.WorkerResult) (sheetId string, hasCostSheet bool
 	}
 
 	if sheetFound {
-		fmt.Println("No Cost Sheet was found. \n Using the pricing sheet : ", resultFileDetails.Name)
+		logger.Println("No Cost Sheet was found. \n Using the pricing sheet : ", resultFileDetails.Name)
 		return sheetId, hasCostSheet, sheetFound, name
 	}
 
@@ -193,40 +341,46 @@ func decideSheet(result modules.WorkerResult) (sheetId string, hasCostSheet bool
 
 }
 
-func ShouldBeSentToCost(sheetID string) (cost int, hasCost bool, err error) {
-	var sheetAcceptedOffer = "T3"
-	sheetRange := fmt.Sprintf("Final Offer!%s", sheetAcceptedOffer)
-	fmt.Println("Sheet Range: ", sheetRange)
+// readPricingSheet fetches the title, accepted offer and offer rows of a pricing
+// sheet in a single Spreadsheets.Get, retrying on Google errors.
+func readPricingSheet(ctx context.Context, logger *log.Logger, sheetID string) (*models.PricingSheet, error) {
+	logger.Println("Sheet Ranges: ", models.AcceptedOfferRange, models.OfferRowsRange)
 	callStartTime := time.Now()
 	defer func() {
-		timeTaken := time.Since(callStartTime)
-		timeSleepingGettingCost += int(timeTaken.Seconds())
-		fmt.Println("Time taken to get cost: ", timeTaken)
+		logger.Println("Time taken to get cost: ", time.Since(callStartTime))
 	}()
-	resp, err := sheetsService.Spreadsheets.Values.Get(sheetID, sheetRange).Do()
+	getSheet := func() (*sheets.Spreadsheet, error) {
+		return sheetsService.Spreadsheets.Get(sheetID).
+			Ranges(models.AcceptedOfferRange, models.OfferRowsRange).
+			IncludeGridData(true).
+			Fields(models.PricingSheetFields).
+			Context(ctx).
+			Do()
+	}
+	resp, err := getSheet()
 	if err != nil {
-		fmt.Println("Error getting sheet")
-		fmt.Println(err)
+		logger.Println("Error getting sheet")
+		logger.Println(err)
 		var maxRetries = 3
 		var retryTimeout = 1
 		if strings.Contains(err.Error(), "googleapi: Error 429") {
 			retryTimeout = 60
-			rateLimitSleep++
-			fmt.Println("Google API Limit Reached Waiting for 60 seconds")
+			logger.Println("Google API Limit Reached Waiting for 60 seconds")
 		} else {
-			fmt.Println("Retrying Due to Google Err")
+			logger.Println("Retrying Due to Google Err")
 		}
 		for i := 0; i < maxRetries; i++ {
-			fmt.Println("Retry Attempt: ", i+1)
+			logger.Println("Retry Attempt: ", i+1)
+			waitStart := time.Now()
 			time.Sleep(time.Duration(retryTimeout) * time.Millisecond)
-			resp, err = sheetsService.Spreadsheets.Values.Get(sheetID, sheetRange).Do()
+			recorder.Since(stats.PhaseRetryWait, waitStart, nil)
+			resp, err = getSheet()
 			if err != nil {
 				if strings.Contains(err.Error(), "googleapi: Error 429") {
-					rateLimitSleep++
-					fmt.Println("Google API Limit Reached Waiting for 60 seconds")
+					logger.Println("Google API Limit Reached Waiting for 60 seconds")
 					retryTimeout = 60
 				} else {
-					fmt.Println("Retrying Due to Google Err")
+					logger.Println("Retrying Due to Google Err")
 					retryTimeout = retryTimeout * 2
 				}
 				continue
@@ -234,265 +388,337 @@ func ShouldBeSentToCost(sheetID string) (cost int, hasCost bool, err error) {
 			break
 		}
 		if err != nil {
-			fmt.Println("Retries exhausted")
-			fmt.Println("Error getting sheet")
-			fmt.Println(err)
-			return 0, false, err
+			logger.Println("Retries exhausted")
+			logger.Println("Error getting sheet")
+			logger.Println(err)
+			return nil, err
 		}
 	}
-	fmt.Println("Response: ", modules.PrettyPrint(resp))
-	if len(resp.Values) < 1 {
-		fmt.Println("No data found in row")
+	return models.NewPricingSheet(sheetID, resp)
+}
+
+func ShouldBeSentToCost(logger *log.Logger, pricingSheet *models.PricingSheet) (cost int, hasCost bool, err error) {
+	logger.Println("Accepted Offer: ", pricingSheet.AcceptedOffer)
+	if pricingSheet.AcceptedOffer == "" {
+		logger.Println("No data found in cell")
 		return 0, false, nil
 	}
-	if len(resp.Values[0]) < 1 {
-		fmt.Println("No data found in cell")
-		return 0, false, nil
+	return pricingSheet.Cost()
+}
+
+func CreateCostSheet(ctx context.Context, logger *log.Logger, pricingSheet *models.PricingSheet, parentFolderId string, cost int) (respId string, costSheetName string, err error) {
+	costSheetName = fmt.Sprintf("%s - Cost Sheet - %s", pricingSheet.Title, time.Now().Format("2006-01-02"))
+	resp, err := driveService.Files.Copy(retroCostingTemplateID, &drive.File{
+		Name:    costSheetName,
+		Parents: []string{parentFolderId},
+	}).Context(ctx).Do()
+	if err != nil {
+		logger.Println("Error copying file")
+		logger.Println(err)
+		return "", "", err
 	}
-	fmt.Println("Data: ", resp.Values[0][0])
 
-	currencyStr := resp.Values[0][0].(string)
-	currencyStr = strings.Split(currencyStr, "$")[1]
-	currencyStr = strings.Replace(currencyStr, ",", "", -1)
+	logger.Println("File copied successfully")
+	logger.Println("File ID: ", resp.Id)
 
-	cost, err = strconv.Atoi(currencyStr)
+	// Written RAW so SKUs like "00123" or "3-4" stay text; the cost in S3 is
+	// an int, so it is still a number.
+	update, err := sheetsService.Spreadsheets.Values.BatchUpdate(resp.Id, &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "RAW",
+		Data: []*sheets.ValueRange{
+			{
+				Range:          "Offer Template!A2:D",
+				Values:         pricingSheet.OfferRows,
+				MajorDimension: "ROWS",
+			},
+			{
+				Range:          "Offer Template!S3",
+				Values:         [][]interface{}{{cost}},
+				MajorDimension: "ROWS",
+			},
+		},
+	}).Context(ctx).Do()
 	if err != nil {
-		fmt.Println("Error converting currency string to int")
-		fmt.Println(err)
-		panic(err)
+		logger.Println("Error updating sheet")
+		logger.Println(err)
+		return "", "", err
 	}
+	logger.Println("Updated Cells: ", update.TotalUpdatedCells)
+	logger.Println("Cost data and cost updated successfully")
 
-	return cost, true, nil
+	return resp.Id, costSheetName, nil
 }
 
-func CreateCostSheet(sheetID string, parentFolderId string, cost int) (respId string, costSheetName string, err error) {
-	costDataRange := "A2:D"
-	costDataRange = fmt.Sprintf("Final Offer!%s", costDataRange)
-
-	// Get the title from the sheet
-	sheetToCopy, err := sheetsService.Spreadsheets.Get(sheetID).Do()
-	if err != nil {
-		fmt.Println("Error getting sheet")
-		fmt.Println("Sheet ID: ", sheetID)
-		fmt.Println(err)
-		return "", "", err
+// recordPoNumber keeps the PO number on the folder and its cost sheet so the
+// PO can be traced back to them later. The PO exists either way, so a failure
+// is only logged.
+func recordPoNumber(ctx context.Context, logger *log.Logger, entry *models.FolderReport, folderId string, costSheetID string, data models.DriveParserData) {
+	entry.PoNumber = data.PoNumber
+	entry.PoResponseStatus = data.PoResponseStatus
+	logger.Println("PO Number: ", data.PoNumber)
+	for _, fileId := range []string{folderId, costSheetID} {
+		err := modules.RecordPoNumber(ctx, fileId, data.PoNumber, data.PoResponseStatus)
+		if err != nil {
+			logger.Println("Error recording PO number on ", fileId)
+			logger.Println(err)
+		}
 	}
-	title := sheetToCopy.Properties.Title
+}
+
+// rejectedItemsTab is the cost sheet tab listing the items the Drive Parser
+// left off the PO.
+const rejectedItemsTab = "Rejected Items"
 
-	costData, err := sheetsService.Spreadsheets.Values.Get(sheetID, costDataRange).Do()
+// writeRejectedItemsTab lists items on the Rejected Items tab of the cost
+// sheet, adding the tab the first time and replacing its contents after.
+func writeRejectedItemsTab(ctx context.Context, logger *log.Logger, sheetID string, items []models.BadItem) error {
+	spreadsheet, err := sheetsService.Spreadsheets.Get(sheetID).Fields("sheets.properties.title").Context(ctx).Do()
 	if err != nil {
-		fmt.Println("Error getting sheet")
-		fmt.Println("Sheet ID: ", sheetID)
-		fmt.Println(err)
-		return "", "", err
+		return err
 	}
-	costSheetName = fmt.Sprintf("%s - Cost Sheet - %s", title, time.Now().Format("2006-01-02"))
-	resp, err := driveService.Files.Copy(retroCostingTemplateID, &drive.File{
-		Name:    costSheetName,
-		Parents: []string{parentFolderId},
-	}).Do()
-	if err != nil {
-		fmt.Println("Error copying file")
-		fmt.Println(err)
-		return "", "", err
+	exists := false
+	for _, sheet := range spreadsheet.Sheets {
+		if sheet.Properties != nil && sheet.Properties.Title == rejectedItemsTab {
+			exists = true
+		}
+	}
+	tabRange := fmt.Sprintf("'%s'", rejectedItemsTab)
+	if exists {
+		_, err = sheetsService.Spreadsheets.Values.Clear(sheetID, tabRange, &sheets.ClearValuesRequest{}).Context(ctx).Do()
+	} else {
+		_, err = sheetsService.Spreadsheets.BatchUpdate(sheetID, &sheets.BatchUpdateSpreadsheetRequest{
+			Requests: []*sheets.Request{
+				{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: rejectedItemsTab}}},
+			},
+		}).Context(ctx).Do()
 	}
-
-	fmt.Println("File copied successfully")
-	fmt.Println("File ID: ", resp.Id)
-	copyDataRange := "A2:D"
-	copyDataRange = fmt.Sprintf("Offer Template!%s", copyDataRange)
-
-	costData.Range = copyDataRange
-
-	update, err := sheetsService.Spreadsheets.Values.Update(resp.Id, copyDataRange, costData).ValueInputOption("RAW").Do()
 	if err != nil {
-		fmt.Println("Error updating sheet")
-		fmt.Println(err)
-		return "", "", err
+		return err
 	}
-	fmt.Println("Update: ", update)
-	fmt.Println("Cost data updated successfully")
-
-	update, err = sheetsService.Spreadsheets.Values.Update(resp.Id, "Offer Template!S3", &sheets.ValueRange{
-		Values:         [][]interface{}{{cost}},
-		Range:          "Offer Template!S3",
+	values := [][]interface{}{toRow(models.BadItemHeader)}
+	for _, item := range items {
+		values = append(values, toRow(item.Row()))
+	}
+	_, err = sheetsService.Spreadsheets.Values.Update(sheetID, tabRange+"!A1", &sheets.ValueRange{
+		Values:         values,
 		MajorDimension: "ROWS",
-	}).ValueInputOption("USER_ENTERED").Do()
+	}).ValueInputOption("RAW").Context(ctx).Do()
 	if err != nil {
-		fmt.Println("Error updating cost on sheet")
-		fmt.Println(err)
-		return "", "", err
+		return err
 	}
-	fmt.Println("Update: ", update)
-	fmt.Println("Cost updated successfully")
+	logger.Printf("Wrote %d rejected items to the %s tab\n", len(items), rejectedItemsTab)
+	return nil
+}
 
-	return resp.Id, costSheetName, nil
+func toRow(cells []string) []interface{} {
+	row := make([]interface{}, len(cells))
+	for i, cell := range cells {
+		row[i] = cell
+	}
+	return row
 }
 
-func moveToFolder(folderID string, destFolderId string) (bool, error) {
-	_, err := driveService.Files.Update(folderID, &drive.File{}).AddParents(destFolderId).RemoveParents(procurementFolderID).Do()
+func moveToFolder(ctx context.Context, logger *log.Logger, folderID string, destFolderId string) (bool, error) {
+	if dryRun {
+		logger.Println("Dry run: not moving the folder")
+		return false, nil
+	}
+	moveStart := time.Now()
+	_, err := driveService.Files.Update(folderID, &drive.File{}).AddParents(destFolderId).RemoveParents(procurementFolderID).Context(ctx).Do()
+	recorder.Since(stats.PhaseMove, moveStart, err)
 	if err != nil {
-		fmt.Println("Error moving folder")
-		fmt.Println(err)
+		logger.Println("Error moving folder")
+		logger.Println(err)
 		return false, err
 
 	}
-	fmt.Println("Folder moved successfully")
+	logger.Println("Folder moved successfully")
 	return true, nil
 }
 
-func moveToWinsFolder(folderId string) (bool, error) {
-	return moveToFolder(folderId, winsFolderId)
+func moveToWinsFolder(ctx context.Context, logger *log.Logger, folderId string) (bool, error) {
+	return moveToFolder(ctx, logger, folderId, winsFolderId)
 }
-func moveToLossesFolder(folderId string) (bool, error) {
-	return moveToFolder(folderId, lossesFolderId)
+func moveToLossesFolder(ctx context.Context, logger *log.Logger, folderId string) (bool, error) {
+	return moveToFolder(ctx, logger, folderId, lossesFolderId)
 }
 
-func handleNoCostSheet(sheetID string, result modules.WorkerResult, sheetName string) (costSheetId string, shouldSkip bool, needsTimeout bool, err error) {
-	isSuspended, err := modules.IsMarkedSuspended(sheetID)
+func handleNoCostSheet(ctx context.Context, logger *log.Logger, entry *models.FolderReport, sheetID string, result modules.WorkerResult, sheetName string) (costSheetId string, shouldSkip bool, needsTimeout bool, err error) {
+	isSuspended, err := modules.IsMarkedSuspended(ctx, logger, sheetID)
 	if err != nil {
-		fmt.Println("Error checking if sheet is marked suspended")
-		fmt.Println(err)
+		logger.Println("Error checking if sheet is marked suspended")
+		logger.Println(err)
 		return "", true, false, err
 	}
 	if isSuspended {
-		fmt.Println("Sheet is marked suspended")
+		logger.Println("Sheet is marked suspended")
 		return "", true, false, nil
 	}
-	isForgotten, err := modules.IsMarkedForgotten(sheetID)
+	isForgotten, err := modules.IsMarkedForgotten(ctx, logger, sheetID)
 	if err != nil {
-		fmt.Println("Error checking if sheet is marked suspended")
-		fmt.Println(err)
+		logger.Println("Error checking if sheet is marked suspended")
+		logger.Println(err)
 		return "", true, false, err
 	}
 	if isForgotten {
-		fmt.Println("Sheet is marked forgotten")
+		logger.Println("Sheet is marked forgotten")
 		return "", true, false, nil
 	}
-	cost, hasCost, err := ShouldBeSentToCost(sheetID)
+	readStart := time.Now()
+	pricingSheet, err := readPricingSheet(ctx, logger, sheetID)
+	recorder.Since(stats.PhaseCostRead, readStart, err)
 	if err != nil {
-		fmt.Println("Error getting cost")
-		fmt.Println(err)
+		logger.Println("Error reading pricing sheet")
+		logger.Println("Sheet ID: ", sheetID)
+		logger.Println(err)
 		return "", true, true, err
 	}
+	cost, hasCost, err := ShouldBeSentToCost(logger, pricingSheet)
+	if err != nil {
+		logger.Println("Error getting cost")
+		logger.Println(err)
+		return "", true, true, err
+	}
+	if hasCost && dryRun {
+		logger.Println("Dry run: not creating a cost sheet")
+		return "", true, false, nil
+	}
 	if hasCost {
-		createdSheetID, costSheetName, err := CreateCostSheet(sheetID, result.ParentFolderId, cost)
+		createStart := time.Now()
+		createdSheetID, costSheetName, err := CreateCostSheet(ctx, logger, pricingSheet, result.ParentFolderId, cost)
+		recorder.Since(stats.PhaseCostSheet, createStart, err)
 		if err != nil {
-			fmt.Println("Error creating cost sheet")
-			fmt.Println(err)
+			logger.Println("Error creating cost sheet")
+			logger.Println(err)
 			return "", true, true, err
 		}
-		fmt.Println("Cost Sheet ID: ", createdSheetID)
-		fmt.Println("Cost Sheet Name: ", costSheetName)
-		fmt.Println("Cost Sheet created successfully")
+		logger.Println("Cost Sheet ID: ", createdSheetID)
+		logger.Println("Cost Sheet Name: ", costSheetName)
+		logger.Println("Cost Sheet created successfully")
 		return createdSheetID, false, true, nil
 	}
 
-	fmt.Println("No cost found")
-	fmt.Println("Sheet Age: ", result.Age)
-	if result.Age >= 60 {
-		fmt.Println("Sheet is older than 60 days -> Checking Insightly to see if it is lost")
-		var oppId = strings.Split(sheetName, "-")[2]
+	logger.Println("No cost found")
+	logger.Println("Sheet Age: ", result.Age)
+	if result.Age >= agedFolderDays {
+		logger.Println("Sheet is older than 60 days -> Checking Insightly to see if it is lost")
+		var oppId = opportunityIdFromName(sheetName)
 		if oppId == "" {
-			fmt.Println("No opportunity ID found")
-			fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+			logger.Println("No opportunity ID found")
+			logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
 			return "", true, true, nil
 		}
-		fmt.Println("Opportunity ID: ", oppId)
-		var i models.InsightlyData
-		message, err := i.GetOpportunity(oppId)
+		logger.Println("Opportunity ID: ", oppId)
+		entry.OpportunityId = oppId
+		decideStart := time.Now()
+		decideCtx, span := tracing.Start(ctx, "insightly decide", attribute.String("insightly.opportunity_id", oppId))
+		decision, err := insightlyClient.Decide(decideCtx, oppId, config.OpportunityRules)
+		if err == nil {
+			span.SetAttributes(attribute.String("insightly.action", string(decision.Action)))
+		}
+		tracing.End(span, err)
+		recorder.Since(stats.PhaseInsightly, decideStart, err)
 		if err != nil {
-			fmt.Println("Error getting opportunity. Opportunity may not exist.")
-			fmt.Println(err)
-			if strings.Contains(err.Error(), "json: cannot unmarshal") {
-				fmt.Println("Opportunity not found")
-				folderWasMoved, err := moveToLossesFolder(result.ParentFolderId)
-				if err != nil {
-					fmt.Println("Error moving folder")
-					fmt.Println(err)
-					return "", true, true, err
-				}
-				if folderWasMoved {
-					fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-					return "", true, true, nil
-				}
-				fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-				return "", true, true, nil
-			}
+			logger.Println("Error getting opportunity")
+			logger.Println(err)
 			return "", true, true, err
 		}
-		fmt.Println(message)
-		if i.IsAbandoned() {
-			fmt.Println("Opportunity is abandoned")
-			folderWasMoved, err := moveToLossesFolder(result.ParentFolderId)
+		metrics.Decisions.Inc(string(decision.Action))
+		entry.SetOpportunity(decision.Opportunity, decision.Action)
+		state := entry.OpportunityState
+		logger.Println("Opp State: ", state)
+		var owner models.OpportunityOwner
+		if decision.Opportunity != nil {
+			owner = decision.Opportunity.Owner
+			logger.Println("Opp Pipeline: ", entry.Pipeline)
+			logger.Println("Opp Stage: ", entry.Stage)
+			logger.Println("Opp Owner: ", owner)
+			entry.CustomFields = decision.Opportunity.ReportCustomFields(config.Insightly.ReportFields)
+			for label, value := range entry.CustomFields {
+				logger.Printf("Opp %s: %s\n", label, value)
+			}
+		}
+		logger.Println("Action: ", decision.Action)
+		switch decision.Action {
+		case models.ActionMoveToLosses:
+			_, err := moveToLossesFolder(ctx, logger, result.ParentFolderId)
 			if err != nil {
-				fmt.Println("Error moving folder")
-				fmt.Println(err)
+				logger.Println("Error moving folder")
+				logger.Println(err)
 				return "", true, true, err
 			}
-			if folderWasMoved {
-				fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-				return "", true, true, nil
-			}
-		}
-		if i.IsWon() {
-			fmt.Println("Opportunity is won")
-			folderWasMoved, err := moveToWinsFolder(result.ParentFolderId)
+			writeBackToInsightly(ctx, logger, entry, models.WriteBackMovedToLosses, models.WriteBackValues{})
+			logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+			return "", true, true, nil
+		case models.ActionMoveToWins:
+			_, err := moveToWinsFolder(ctx, logger, result.ParentFolderId)
 			if err != nil {
-				fmt.Println("Error moving folder")
-				fmt.Println(err)
+				logger.Println("Error moving folder")
+				logger.Println(err)
 				return "", true, true, err
 			}
-			if folderWasMoved {
-				fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+			writeBackToInsightly(ctx, logger, entry, models.WriteBackMovedToWins, models.WriteBackValues{})
+			logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+			return "", true, true, nil
+		case models.ActionMarkSuspended:
+			if dryRun {
+				logger.Println("Dry run: not marking the sheet as suspended")
 				return "", true, true, nil
 			}
-		}
-		if i.IsSuspended() {
-			fmt.Println("Opportunity is suspended")
-			marked, err := modules.MarkSheetSuspended(sheetID, sheetName)
+			marked, err := modules.MarkSheetSuspended(ctx, logger, sheetID, sheetName, owner)
 			if err != nil {
-				fmt.Println("Error marking sheet suspended")
-				fmt.Println(err)
+				logger.Println("Error marking sheet suspended")
+				logger.Println(err)
 				return "", true, true, err
 			}
 			if marked {
-				fmt.Println("Sheet marked as suspended")
+				logger.Println("Sheet marked as suspended")
 			} else {
-				fmt.Println("Sheet not marked as suspended")
+				logger.Println("Sheet not marked as suspended")
 			}
 			return "", true, true, nil
-		}
-		if i.IsOpen() {
-			fmt.Println("Sheet is older than 60 days -> Marking as forgotten")
-			marked, err := modules.MarkSheetForgotten(sheetID, sheetName)
+		case models.ActionMarkForgotten:
+			logger.Println("Sheet is older than 60 days -> Marking as forgotten")
+			if dryRun {
+				logger.Println("Dry run: not marking the sheet as forgotten")
+				return "", true, true, nil
+			}
+			marked, err := modules.MarkSheetForgotten(ctx, logger, sheetID, sheetName, owner)
 			if err != nil {
-				fmt.Println("Error marking sheet as forgotten")
-				fmt.Println(err)
+				logger.Println("Error marking sheet as forgotten")
+				logger.Println(err)
 				return "", true, true, err
 			}
 			if marked {
-				fmt.Println("Sheet marked as forgotten")
+				logger.Println("Sheet marked as forgotten")
 			} else {
-				fmt.Println("Sheet not marked as forgotten")
+				logger.Println("Sheet not marked as forgotten")
 			}
 			return "", true, true, nil
+		case models.ActionNotifyOnly:
+			logger.Println("NOTICE: Opportunity needs attention, no action taken")
+			logger.Println("Opp ID: ", oppId)
+			logger.Println("Opp Owner: ", owner)
+			logger.Println("Opp State: ", state)
+			logger.Println("Sheet: ", sheetName)
+			return "", true, true, nil
+		case models.ActionNone:
+			if !decision.Matched {
+				logger.Println("No opportunity rule matched")
+			}
+			logger.Println("Opp ID: ", oppId)
+			logger.Println("Opp State: ", state)
+			return "", true, true, nil
 		}
-		fmt.Println("Opportunity is not lost or suspended")
-		fmt.Println("Opp ID: ", oppId)
-		fmt.Println("Opp State: ", i.OpportunityState)
 	}
 	return "", true, true, err
 }
 
-func main() {
-	elapsedTimeWaitingForAPI := 0
-	processedFiles := 0
-	sleeplessFiles := 0
-	callsToDriveParser := 0
-	posGenerated := 0
-
-	var fileList []*drive.File
+// listProcurementFolders sends every folder under the Procurement folder on
+// folders a page at a time, closing it once the listing is done or has
+// failed.
+func listProcurementFolders(folders chan<- *drive.File) error {
+	defer close(folders)
 	files, err := driveService.
 		Files.
 		List().
@@ -500,220 +726,517 @@ func main() {
 		Q(fmt.Sprintf("'%s' in parents and mimeType = 'application/vnd.google-apps.folder'", procurementFolderID)).
 		Do()
 	if err != nil {
-		fmt.Println("Error fetching files")
-		panic(err)
+		log.Println("Error fetching files")
+		return err
 	}
-	fileList = append(fileList, files.Files...)
-	if files.NextPageToken != "" {
-		for files.NextPageToken != "" {
-			fmt.Println("Next page token found")
-			files, err = driveService.Files.List().
-				Fields("files(id, name), nextPageToken").
-				Q(fmt.Sprintf("'%s' in parents and mimeType = 'application/vnd.google-apps.folder'", procurementFolderID)).
-				PageToken(files.NextPageToken).Do()
-			if err != nil {
-				fmt.Println("Error getting files from folder")
-				panic(err)
-			}
-			fileList = append(fileList, files.Files...)
+	for _, file := range files.Files {
+		folders <- file
+	}
+	for files.NextPageToken != "" {
+		log.Println("Next page token found")
+		files, err = driveService.Files.List().
+			Fields("files(id, name), nextPageToken").
+			Q(fmt.Sprintf("'%s' in parents and mimeType = 'application/vnd.google-apps.folder'", procurementFolderID)).
+			PageToken(files.NextPageToken).Do()
+		if err != nil {
+			log.Println("Error getting files from folder")
+			return err
+		}
+		for _, file := range files.Files {
+			folders <- file
 		}
 	}
+	return nil
+}
 
-	fmt.Printf("Found %d files", len(fileList))
-	jobs, results, wg := modules.SetupWorkers(10, len(fileList))
-
-	for _, file := range fileList {
-		slices := strings.Split(file.Name, "-")
-		if len(slices) > 2 {
-			fmt.Println("File Name: ", file.Name)
-			jobs <- file.Id
-		} else {
+// knownOpportunities returns the opportunity IDs of the folders seen by the
+// last run that are old enough to be checked against Insightly, along with
+// the IDs of every folder seen.
+func knownOpportunities(sweepState *models.SweepState, now time.Time) ([]string, map[string]bool) {
+	var aged []string
+	referenced := map[string]bool{}
+	for _, folder := range sweepState.Folders {
+		oppId := opportunityIdFromName(folder.Name)
+		if _, err := strconv.Atoi(oppId); err != nil || referenced[oppId] {
 			continue
 		}
+		referenced[oppId] = true
+		if !folder.CreatedAt.IsZero() && now.Sub(folder.CreatedAt) >= agedFolderDays*24*time.Hour {
+			aged = append(aged, oppId)
+		}
 	}
-	close(jobs)
-	fmt.Println("Jobs channel closed")
+	return aged, referenced
+}
 
-	wg.Wait()
-	fmt.Println("WaitGroup finished")
+// listIncrementalFolders returns the folders that need another look since the
+// last run: those with changed files, those that have just become old enough
+// to be checked against Insightly and those that errored last time.
+func listIncrementalFolders(sweepState *models.SweepState) ([]*drive.File, string, error) {
+	fileList, nextPageToken, err := modules.ChangedFolders(sweepState.PageToken, procurementFolderID, sweepState)
+	if err != nil {
+		return nil, "", err
+	}
+	log.Printf("Found %d changed folders\n", len(fileList))
+	changed := map[string]bool{}
+	for _, file := range fileList {
+		changed[file.Id] = true
+	}
+	requeue := func(folderId string, reason string) error {
+		if changed[folderId] {
+			return nil
+		}
+		stillPending, err := modules.IsInFolder(folderId, procurementFolderID)
+		if err != nil {
+			return err
+		}
+		if !stillPending {
+			sweepState.Forget(folderId)
+			return nil
+		}
+		changed[folderId] = true
+		log.Println(reason, sweepState.Folders[folderId].Name)
+		fileList = append(fileList, &drive.File{Id: folderId, Name: sweepState.Folders[folderId].Name})
+		return nil
+	}
+	for _, folderId := range sweepState.CrossedAge(agedFolderDays, start) {
+		err = requeue(folderId, "Folder crossed the age threshold: ")
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	for folderId := range sweepState.Errored {
+		err = requeue(folderId, "Retrying folder that errored last run: ")
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return fileList, nextPageToken, nil
+}
 
-	close(results)
-	fmt.Println("Results channel closed")
-	fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+// processResult decides what to do with a single procurement folder and carries
+// it out. It returns the outcome to checkpoint and whether the caller should
+// sleep before moving on to the next folder.
+func processResult(ctx context.Context, logger *log.Logger, result modules.WorkerResult, entry *models.FolderReport) (outcome string, needsTimeout bool, err error) {
+	if result.Err != nil {
+		logger.Println("Error listing folder")
+		logger.Println(result.Err)
+		logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+		return models.OutcomeError, true, result.Err
+	}
+	var costSheetID string
+	sheetID, hasCostSheet, sheetFound, chosenSheetName := decideSheet(logger, result)
+	entry.SheetName = chosenSheetName
+	if !sheetFound {
+		logger.Println("Sheet not found")
+		logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+		return models.OutcomeNoSheet, true, nil
+	}
 
-	for result := range results {
-		fmt.Println()
-		processedFiles++
-		var costSheetID string
-		sheetID, hasCostSheet, sheetFound, chosenSheetName := decideSheet(result)
-		if !sheetFound {
-			fmt.Println("Sheet not found")
-			fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-			countDownTimer(timeout)
-			continue
+	if hasCostSheet {
+		costSheetID = sheetID
+	} else {
+		csID, shouldSkip, needsTimeout, handleCostErr := handleNoCostSheet(ctx, logger, entry, sheetID, result, chosenSheetName)
+		if handleCostErr != nil {
+			logger.Println("Error handling no cost sheet")
+			logger.Println(handleCostErr)
+			return models.OutcomeError, true, handleCostErr
 		}
+		if shouldSkip {
+			logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+			return models.OutcomeSkipped, needsTimeout, nil
+		}
+		if csID == "" {
+			logger.Println("No cost sheet ID found")
+			logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+			return models.OutcomeNoSheet, true, nil
+		}
+		costSheetID = csID
+	}
+
+	sheetUrl := fmt.Sprintf("https://docs.google.com/spreadsheets/d/%s/edit#gid=0", costSheetID)
+	if dryRun {
+		logger.Println("Dry run: not calling the Drive Parser with Sheet URL -> : ", sheetUrl)
+		logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+		return models.OutcomeSkipped, false, nil
+	}
+	logger.Println("Calling the Drive Parser with Sheet URL -> : ", sheetUrl)
+	callsToDriveParser.Add(1)
+	jsonData, err := CallDriveParser(ctx, logger, sheetUrl)
+	if err != nil {
+		logger.Println("Error calling Drive Parser")
+		logger.Println(err)
+		return models.OutcomeError, true, err
+	}
+	logger.Println("Response Message: ", jsonData.Message)
+	return handleDriveParserResponse(ctx, logger, result, entry, costSheetID, sheetUrl, jsonData)
+}
 
-		if hasCostSheet {
-			costSheetID = sheetID
+// handleDriveParserResponse acts on what the Drive Parser did with the cost
+// sheet. Every models.DriveParserResult has a case here; add one alongside any
+// new result.
+func handleDriveParserResponse(ctx context.Context, logger *log.Logger, result modules.WorkerResult, entry *models.FolderReport, costSheetID string, sheetUrl string, jsonData *models.DriveParserResponse) (outcome string, needsTimeout bool, err error) {
+	resultCode := jsonData.Result()
+	logger.Println("Result: ", resultCode)
+	metrics.DriveParserResults.Inc(string(resultCode))
+	badItems := jsonData.BadItems()
+	if len(badItems) > 0 {
+		entry.BadItems = badItems
+		logger.Printf("%d items were rejected\n", len(badItems))
+		for _, item := range badItems {
+			logger.Printf("Rejected: %s %s (SKU %q, Qty %q): %s\n", item.Manufacturer, item.Model, item.Sku, item.Quantity, item.Reason)
+		}
+		err := writeRejectedItemsTab(ctx, logger, costSheetID, badItems)
+		if err != nil {
+			// The items are still in the run report and the CSV.
+			logger.Println("Error writing rejected items to the cost sheet")
+			logger.Println(err)
+		}
+	}
+	resultCode = flaggedResult(resultCode, jsonData.Error)
+	switch resultCode {
+	case models.ResultPoCreated, models.ResultPoAlreadyExists, models.ResultAlreadyProcessed, models.ResultAccepted:
+		moved, err := moveToWinsFolder(ctx, logger, result.ParentFolderId)
+		if err != nil {
+			logger.Println("Error moving folder")
+			logger.Println(err)
+			logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+			return models.OutcomeError, true, err
+		}
+		if moved {
+			logger.Println("Folder moved successfully")
+		}
+		if jsonData.Data.PoNumber != "" {
+			recordPoNumber(ctx, logger, entry, result.ParentFolderId, costSheetID, jsonData.Data)
+		}
+		values := models.WriteBackValues{PoNumber: jsonData.Data.PoNumber, SheetUrl: sheetUrl}
+		if resultCode == models.ResultPoCreated {
+			writeBackToInsightly(ctx, logger, entry, models.WriteBackPoCreated, values)
+			posGenerated.Add(1)
+			logger.Println("Sheet was successfully processed and sent to sku vault")
+			logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+			return models.OutcomePoCreated, true, nil
+		}
+		if moved {
+			writeBackToInsightly(ctx, logger, entry, models.WriteBackMovedToWins, values)
+		}
+		if resultCode == models.ResultAccepted {
+			logger.Println("No Explicit handler for : ", jsonData.Message)
 		} else {
-			csID, shouldSkip, needsTimeout, handleCostErr := handleNoCostSheet(sheetID, result, chosenSheetName)
-			if handleCostErr != nil {
-				fmt.Println("Error handling no cost sheet")
-				fmt.Println(handleCostErr)
-				countDownTimer(timeout)
-				continue
-			}
-			if shouldSkip {
-				fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-				if needsTimeout {
-					countDownTimer(timeout)
-				} else {
-					sleeplessFiles++
-				}
-				continue
-			}
-			if csID == "" {
-				fmt.Println("No cost sheet ID found")
-				fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-				countDownTimer(timeout)
-				continue
-			}
-			costSheetID = csID
+			logger.Println("Sheet has already been processed")
+		}
+		logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+		return models.OutcomeSubmitted, true, nil
+	case models.ResultSupplierUnknown:
+		logger.Println("Supplier Name could not be determined")
+		logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+		return models.OutcomeRejected, true, nil
+	case models.ResultItemsSkipped:
+		logger.Println("Some items were skipped because they had no SKU or Quantity")
+		logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+		return models.OutcomeRejected, true, nil
+	case models.ResultUpstreamBadGateway:
+		logger.Println("Retrying Sheet")
+		retried, err := retryDriveParser(ctx, logger, sheetUrl)
+		if err != nil {
+			logger.Println("Unable to process sheet after retries")
+			logger.Println(err)
+			logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+			return models.OutcomeError, true, err
 		}
+		if retried.Result() == models.ResultUpstreamBadGateway {
+			logger.Println("Unable to process sheet after retries")
+			logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+			return models.OutcomeError, true, fmt.Errorf("drive parser returned: %s", strings.TrimSpace(retried.Message))
+		}
+		logger.Println("Retry Response Message: ", retried.Message)
+		return handleDriveParserResponse(ctx, logger, result, entry, costSheetID, sheetUrl, retried)
+	case models.ResultRejected:
+		logger.Println("No Explicit handler for : ", strings.TrimSpace(jsonData.Message))
+		logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+		return models.OutcomeRejected, true, nil
+	}
+	logger.Println("Unhandled Drive Parser result: ", resultCode)
+	logger.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+	return models.OutcomeError, true, fmt.Errorf("unhandled drive parser result %s", resultCode)
+}
+
+// flaggedResult lets the Drive Parser's error flag decide between the wins
+// folder and a rejection, as it did before result codes: a response without
+// the flag went through whatever its message, and one with it was rejected
+// unless the PO already exists.
+func flaggedResult(resultCode models.DriveParserResult, flagged bool) models.DriveParserResult {
+	switch {
+	case !flagged:
+		switch resultCode {
+		case models.ResultSupplierUnknown, models.ResultItemsSkipped, models.ResultUpstreamBadGateway, models.ResultRejected:
+			return models.ResultAccepted
+		}
+	case resultCode == models.ResultPoCreated, resultCode == models.ResultAlreadyProcessed, resultCode == models.ResultAccepted:
+		return models.ResultRejected
+	}
+	return resultCode
+}
+
+func sweepCommand(args []string) int {
+	flags := commandFlags("sweep")
+	addRunFlags(flags)
+	err := flags.Parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if flags.NArg() > 0 {
+		log.Println("sweep takes no arguments")
+		return exitUsage
+	}
+	err = setupSweep()
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	defer abortOnPanic()
+	err = startRunServices()
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	defer shutdownTracing()
+
+	err = beginRun(triggerManual)
+	if err != nil {
+		log.Println(err)
+		return lockedExit(err)
+	}
+	err = runSweep(sweepOptions{incremental: incremental, resume: resume})
+	if err != nil {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// sweepOptions choose how a single run treats the folders it finds.
+type sweepOptions struct {
+	incremental bool
+	resume      bool
+}
 
-		sheetUrl := fmt.Sprintf("https://docs.google.com/spreadsheets/d/%s/edit#gid=0", costSheetID)
-		fmt.Println("Calling the Drive Parser with Sheet URL -> : ", sheetUrl)
-		jsonData := models.DriveParserResponse{}
-		startApiCall := time.Now()
-		callsToDriveParser++
-		err := CallDriveParser(fmt.Sprintf(`{"url": "%s"}`, sheetUrl), &jsonData)
+// runSweep goes through the procurement folders once. The run must have been
+// started with beginRun; runSweep finishes it, reporting the run as aborted
+// when it returns an error.
+func runSweep(options sweepOptions) (err error) {
+	defer func() {
 		if err != nil {
-			fmt.Println("Error calling Drive Parser")
-			fmt.Println(err)
-			countDownTimer(timeout)
-			continue
+			log.Println(err)
+			finishRun(false, err.Error())
 		}
-		elapsedTimeWaitingForAPI += int(time.Since(startApiCall).Seconds())
-		fmt.Println("Response Message: ", jsonData.Message)
-		if !jsonData.Error {
+	}()
+	err = resolveWinsFolder()
+	if err != nil {
+		return fmt.Errorf("getting wins folder: %w", err)
+	}
 
-			moved, err := moveToWinsFolder(result.ParentFolderId)
-			if err != nil {
-				fmt.Println("Error moving folder")
-				fmt.Println(err)
-				countDownTimer(timeout)
-				continue
+	var checkpoint models.Checkpoint
+	err = checkpoint.GetCheckpoint()
+	if err != nil {
+		return fmt.Errorf("loading checkpoint: %w", err)
+	}
+	if !options.resume || checkpoint.Completed {
+		if options.resume {
+			log.Println("Last run completed. Nothing to resume, starting a new run")
+		}
+		checkpoint.Reset(start)
+	} else {
+		log.Println("Resuming run started at: ", checkpoint.Start)
+		log.Printf("%d folders already recorded\n", len(checkpoint.Folders))
+	}
+
+	var sweepState models.SweepState
+	err = sweepState.GetSweepState()
+	if err != nil {
+		return fmt.Errorf("loading sweep state: %w", err)
+	}
+	aged, referenced := knownOpportunities(&sweepState, start)
+	if len(referenced) > 0 {
+		pruned := insightlyClient.Cache.Prune(referenced)
+		log.Printf("Pruned %d cached Insightly opportunities no folder refers to\n", pruned)
+	}
+	fetched, err := insightlyClient.Prefetch(context.Background(), aged)
+	if err != nil {
+		// The cache still works entry by entry, so a failed prefetch only
+		// costs extra calls later on.
+		log.Println("Error prefetching Insightly opportunities")
+		log.Println(err)
+	}
+	log.Printf("Fetched %d of %d aged Insightly opportunities\n", fetched, len(aged))
+
+	bufferSize := config.Concurrency.Enumerate * 2
+	folders := make(chan *drive.File, bufferSize)
+	// listErr gets the outcome of listing the folders; an incremental run has
+	// listed them already.
+	listErr := make(chan error, 1)
+	incrementalRun := options.incremental && sweepState.PageToken != ""
+	var nextPageToken string
+	// previousFolders is what the last run knew, for the folders a resumed
+	// full sweep skips.
+	previousFolders := sweepState.Folders
+	if incrementalRun {
+		log.Println("Running incremental sweep from the last saved page token")
+		fileList, token, err := listIncrementalFolders(&sweepState)
+		if err != nil {
+			return fmt.Errorf("listing changed folders: %w", err)
+		}
+		nextPageToken = token
+		go func() {
+			for _, file := range fileList {
+				folders <- file
 			}
+			close(folders)
+			listErr <- nil
+		}()
+	} else {
+		if options.incremental {
+			log.Println("No saved page token found. Running a full sweep")
+		}
+		// Take the token before listing so anything changed during this run
+		// is picked up by the next incremental one.
+		nextPageToken, err = modules.GetStartPageToken()
+		if err != nil {
+			return fmt.Errorf("getting start page token: %w", err)
+		}
+		sweepState.Folders = map[string]models.KnownFolder{}
+		sweepState.Errored = map[string]bool{}
+		go func() {
+			defer abortOnPanic()
+			listErr <- listProcurementFolders(folders)
+		}()
+	}
 
-			if moved {
-				fmt.Println("Folder moved successfully")
+	// Folders are fed to the workers while they are still being listed, and
+	// processed as soon as their files are known.
+	handled := checkpoint.Handled()
+	var folderNames sync.Map
+	// skipped is only read once the results are drained, after the feeder
+	// has closed jobs.
+	var skipped []*drive.File
+	jobs, results := modules.SetupWorkers(config.Concurrency.Enumerate, bufferSize)
+	go func() {
+		defer abortOnPanic()
+		for file := range folders {
+			totalFiles.Add(1)
+			slices := strings.Split(file.Name, "-")
+			if len(slices) <= 2 {
+				continue
 			}
-			if jsonData.Message == "Sheet has already been processed" || jsonData.Message == "PO Already Exists" {
-				fmt.Println("Sheet has already been processed")
-				fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-			} else if jsonData.Message == "PO Created Successfully" {
-				posGenerated++
-				fmt.Println("Sheet was successfully processed and sent to sku vault")
-				fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-			} else {
-				fmt.Println("No Explicit handler for : ", jsonData.Message)
-				fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+			if handled[file.Id] {
+				log.Println("Already handled, skipping: ", file.Name)
+				resumedFiles.Add(1)
+				skipped = append(skipped, file)
+				continue
 			}
-			countDownTimer(timeout)
-			continue
+			folderNames.Store(file.Id, file.Name)
+			jobs <- file.Id
+		}
+		close(jobs)
+		log.Printf("Found %d files\n", totalFiles.Load())
+	}()
 
+	runReport := models.RunReport{Start: start}
+	for report := range processStage(results, config.Concurrency.Process) {
+		processedFiles.Add(1)
+		metrics.FoldersProcessed.Inc(report.outcome)
+		result := report.result
+		folderName, _ := folderNames.Load(result.ParentFolderId)
+		known := sweepState.Folders[result.ParentFolderId]
+		known.Name = folderName.(string)
+		// A folder that could not be listed keeps the age it had.
+		if result.Err == nil {
+			known.CreatedAt = result.CreatedAt
 		}
-		trimmedMessage := strings.TrimSpace(jsonData.Message)
-		switch trimmedMessage {
-		case "Supplier Name could not be determined":
-			fmt.Println("Supplier Name could not be determined")
-			fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-		case "Some items were skipped because they had no SKU or Quantity":
-			fmt.Println("Items: ", jsonData.Data)
-			fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-		case "Error updating sheet: Request failed with status code 502":
-			fmt.Println("Retrying Sheet")
-			for retries := 0; retries < 2; retries++ {
-				err := CallDriveParser(fmt.Sprintf(`{"url": "%s"}`, sheetUrl), &jsonData)
-				if err != nil {
-					fmt.Println("Error calling Drive Parser")
-					fmt.Println(err)
-					continue
-				}
-				if jsonData.Message == "Error updating sheet: Request failed with status code 502" {
-					fmt.Println("Retrying")
-					continue
-				}
-				break
-			}
-			fmt.Println("Unable to process sheet after retries")
-			fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-		case "PO Already Exists":
-			fmt.Println("PO Already Exists")
-			moved, err := moveToWinsFolder(result.ParentFolderId)
-			if err != nil {
-				fmt.Println("Error moving folder")
-				fmt.Println(err)
-				fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-			}
-			if moved {
-				fmt.Println("Folder moved successfully")
-				fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
+		sweepState.Folders[result.ParentFolderId] = known
+		sweepState.RecordOutcome(result.ParentFolderId, report.outcome)
+		entry := report.entry
+		entry.FolderName = folderName.(string)
+		entry.Outcome = report.outcome
+		if report.err != nil {
+			entry.Error = report.err.Error()
+		}
+		report.entry = entry
+		report.print()
+		runReport.Add(entry)
+		// A dry run changes nothing, so it must not leave folders for
+		// -resume or -incremental to skip.
+		if !dryRun {
+			checkpoint.Record(result.ParentFolderId, report.outcome, report.err)
+			saveErr := checkpoint.Save()
+			if saveErr != nil {
+				log.Println("Error saving checkpoint")
+				log.Println(saveErr)
 			}
-		default:
-			fmt.Println("No Explicit handler for : ", trimmedMessage)
-			fmt.Println("-=-=-=-=-=-=-=-=-=-=-=-")
-		}
-		countDownTimer(timeout)
-		continue
-	}
-	fmt.Println()
-	fmt.Println("All files processed")
-
-	end := time.Now()
-	elapsed := end.Sub(start)
-
-	fmt.Println(fmt.Sprintf("Processed %d Files", processedFiles))
-	fmt.Println(fmt.Sprintf("Total Execution time: %s", elapsed))
-	fmt.Println(fmt.Sprintf("Total POs Generated: %d", posGenerated))
-	secondsWaitingForRateLimit := rateLimitSleep * 60
-	fmt.Println(fmt.Sprintf("Total Time Waiting for Rate Limit: %d seconds", secondsWaitingForRateLimit))
-	secondsSleeping := ((processedFiles - 2 - sleeplessFiles) * timeout) + secondsWaitingForRateLimit
-
-	durationSleeping := time.Duration(secondsSleeping * int(time.Second))
-	percentOfExecutionTime := (durationSleeping.Seconds() / elapsed.Seconds()) * 100
-	fmt.Println(fmt.Sprintf("Total time sleeping: %s || %.2f%% Percentage of total execution time ", durationSleeping, percentOfExecutionTime))
-
-	durationWaitingForCost := time.Duration(timeSleepingGettingCost) * time.Second
-	percentOfExecutionTime = (durationWaitingForCost.Seconds() / elapsed.Seconds()) * 100
-	fmt.Println(fmt.Sprintf("Total time waiting for cost: %s || %.2f%% Percentage of total execution time", durationWaitingForCost, percentOfExecutionTime))
-
-	durationWaitingForApi := time.Duration(elapsedTimeWaitingForAPI) * time.Second
-	percentOfExecutionTime = (durationWaitingForApi.Seconds() / elapsed.Seconds()) * 100
-	fmt.Println(fmt.Sprintf("Total Calls to Drive Parser API: %d", callsToDriveParser))
-	fmt.Println(fmt.Sprintf("Total time waiting for Drive Parser API: %s || %.2f%% Percentage of total execution time", durationWaitingForApi, percentOfExecutionTime))
-
-	localProcessingTime := elapsed - durationWaitingForApi - durationSleeping - durationWaitingForCost
-	percentOfExecutionTime = (localProcessingTime.Seconds() / elapsed.Seconds()) * 100
-	fmt.Println(fmt.Sprintf("Total time Processing Data locally: %s || %.2f%% Percentage of total execution time", localProcessingTime, percentOfExecutionTime))
-
-	fmt.Println("Sending Statistics to Surtrics")
-
-	stats := models.Statistics{
-		Start:                             start,
-		End:                               end,
-		Completed:                         true,
-		TotalFiles:                        len(fileList),
-		SkippedFiles:                      sleeplessFiles,
-		ProcessedFiles:                    processedFiles,
-		CallsToDriveParser:                callsToDriveParser,
-		PosGenerated:                      posGenerated,
-		TotalExecutionTime:                elapsed.String(),
-		TotalTimeWaitingForCost:           durationWaitingForCost.String(),
-		TotalTimeSleeping:                 durationSleeping.String(),
-		TotalTimeWaitingForDriveParserApi: durationWaitingForApi.String(),
-	}
-	url := fmt.Sprintf("%s/run", surpriceURLUpdateCost)
-	fmt.Println("Sending Stats to: ", url)
-	stats.SendStats(url, client)
+		}
+		if !report.needsTimeout {
+			sleeplessFiles.Add(1)
+		}
+	}
+	// Folders handled before a resume are still known, so later incremental
+	// runs keep age checking them and watching their files.
+	for _, file := range skipped {
+		if _, ok := sweepState.Folders[file.Id]; ok {
+			continue
+		}
+		known := previousFolders[file.Id]
+		known.Name = file.Name
+		sweepState.Folders[file.Id] = known
+	}
+	// A listing cut short leaves the run incomplete: -resume picks it up
+	// from the checkpoint, and the sweep state is kept as it was so the
+	// folders never listed are not forgotten.
+	listingErr := <-listErr
+	if listingErr != nil {
+		log.Println("Listing the procurement folders failed, not all folders were processed")
+	}
+	if !dryRun && listingErr == nil {
+		checkpoint.Completed = true
+		err = checkpoint.Save()
+		if err != nil {
+			log.Println("Error saving checkpoint")
+			log.Println(err)
+		}
+	}
+	err = insightlyClient.Cache.Save()
+	if err != nil {
+		log.Println("Error saving Insightly cache")
+		log.Println(err)
+	}
+	if !dryRun && listingErr == nil {
+		sweepState.PageToken = nextPageToken
+		sweepState.LastRun = start
+		err = sweepState.Save()
+		if err != nil {
+			log.Println("Error saving sweep state")
+			log.Println(err)
+		}
+	}
+	log.Println()
+	if listingErr == nil {
+		log.Println("All files processed")
+	}
+
+	runReport.End = time.Now()
+	err = runReport.Save()
+	if err != nil {
+		log.Println("Error saving run report")
+		log.Println(err)
+	}
+	badItemCount, err := runReport.SaveBadItems()
+	if err != nil {
+		log.Println("Error saving bad items report")
+		log.Println(err)
+	} else if badItemCount > 0 {
+		log.Println(fmt.Sprintf("%d rejected items written to ./json/badItems.csv", badItemCount))
+	}
+
+	if listingErr != nil {
+		return fmt.Errorf("listing procurement folders: %w", listingErr)
+	}
+	finishRun(true, "")
+	return nil
 }
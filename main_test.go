@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/modules"
+	"github.com/mwalkersigma/drive-parser/surprice/surpricetest"
+	drive "google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testFolderId = "folder-1"
+	testSheetId  = "sheet-1"
+	testWinsId   = "wins"
+	testSheetUrl = "https://docs.google.com/spreadsheets/d/sheet-1/edit#gid=0"
+)
+
+// fakeDrive records the parents added to files, which is how folders are
+// moved, and accepts any other file update.
+type fakeDrive struct {
+	mu    sync.Mutex
+	moves map[string]string
+}
+
+func (d *fakeDrive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if parent := r.URL.Query().Get("addParents"); parent != "" {
+		d.mu.Lock()
+		d.moves[id] = parent
+		d.mu.Unlock()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"id":"` + id + `"}`))
+}
+
+func (d *fakeDrive) movedTo(id string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.moves[id]
+}
+
+// setupRetryTest points the sweep at a scripted Drive Parser and a fake
+// Drive, with retries that do not wait.
+func setupRetryTest(t *testing.T, responses ...surpricetest.Response) *fakeDrive {
+	t.Helper()
+	surpriceServer := surpricetest.NewServer()
+	t.Cleanup(surpriceServer.Close)
+	surpriceServer.Script(testSheetUrl, responses...)
+	surpriceClient = surpriceServer.Client()
+
+	fake := &fakeDrive{moves: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.Handle("PATCH /files/{id}", fake)
+	driveServer := httptest.NewServer(mux)
+	t.Cleanup(driveServer.Close)
+	ds, err := drive.NewService(context.Background(), option.WithEndpoint(driveServer.URL+"/"), option.WithHTTPClient(driveServer.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	driveService = ds
+	modules.Setup(ds, surpriceClient)
+	winsFolderId = testWinsId
+
+	backoff := driveParserRetryBackoff
+	driveParserRetryBackoff = time.Millisecond
+	t.Cleanup(func() { driveParserRetryBackoff = backoff })
+	posGenerated.Store(0)
+	return fake
+}
+
+func fixture(t *testing.T, name string) surpricetest.Response {
+	t.Helper()
+	response, err := surpricetest.Fixture(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// uploadAndHandle sends the sheet and acts on the answer the way
+// processResult does.
+func uploadAndHandle(ctx context.Context, t *testing.T) (string, error) {
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Log(logs.String())
+		}
+	})
+	response, err := CallDriveParser(ctx, logger, testSheetUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := modules.WorkerResult{ParentFolderId: testFolderId}
+	var entry models.FolderReport
+	outcome, _, err := handleDriveParserResponse(ctx, logger, result, &entry, testSheetId, testSheetUrl, response)
+	return outcome, err
+}
+
+func TestRetryAfter502(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []surpricetest.Response
+		outcome   string
+		moved     bool
+		pos       int64
+	}{
+		{
+			name:      "502 then success",
+			responses: []surpricetest.Response{fixture(t, surpricetest.FixtureUpstream502), fixture(t, surpricetest.FixturePoCreated)},
+			outcome:   models.OutcomePoCreated,
+			moved:     true,
+			pos:       1,
+		},
+		{
+			name:      "502 until the retries run out",
+			responses: []surpricetest.Response{fixture(t, surpricetest.FixtureUpstream502)},
+			outcome:   models.OutcomeError,
+		},
+		{
+			name:      "502 then a transport error",
+			responses: []surpricetest.Response{fixture(t, surpricetest.FixtureUpstream502), {Drop: true}},
+			outcome:   models.OutcomeError,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			drive := setupRetryTest(t, test.responses...)
+
+			outcome, err := uploadAndHandle(context.Background(), t)
+			if outcome != test.outcome {
+				t.Errorf("outcome = %s, want %s (err %v)", outcome, test.outcome, err)
+			}
+			if (err != nil) != (test.outcome == models.OutcomeError) {
+				t.Errorf("err = %v for outcome %s", err, outcome)
+			}
+			movedTo := drive.movedTo(testFolderId)
+			if test.moved && movedTo != testWinsId {
+				t.Errorf("folder moved to %q, want the wins folder", movedTo)
+			}
+			if !test.moved && movedTo != "" {
+				t.Errorf("folder moved to %q, want it left in place", movedTo)
+			}
+			if posGenerated.Load() != test.pos {
+				t.Errorf("posGenerated = %d, want %d", posGenerated.Load(), test.pos)
+			}
+		})
+	}
+}
+
+func TestRetryStopsWhenCancelled(t *testing.T) {
+	drive := setupRetryTest(t, fixture(t, surpricetest.FixtureUpstream502))
+	driveParserRetryBackoff = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	logger := log.New(&bytes.Buffer{}, "", 0)
+	response, err := CallDriveParser(ctx, logger, testSheetUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	done := make(chan struct{})
+	var outcome string
+	go func() {
+		defer close(done)
+		var entry models.FolderReport
+		outcome, _, err = handleDriveParserResponse(ctx, logger, modules.WorkerResult{ParentFolderId: testFolderId}, &entry, testSheetId, testSheetUrl, response)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry kept waiting after the context was cancelled")
+	}
+	if outcome != models.OutcomeError || !errors.Is(err, context.Canceled) {
+		t.Errorf("outcome = %s, err = %v, want an error outcome from the cancel", outcome, err)
+	}
+	if drive.movedTo(testFolderId) != "" {
+		t.Error("cancelled retry moved the folder")
+	}
+}
+
+func TestErrorFlagDecidesOutcome(t *testing.T) {
+	tests := []struct {
+		fixture string
+		outcome string
+		moved   bool
+	}{
+		{fixture: surpricetest.FixtureSupplierUnknown, outcome: models.OutcomeRejected},
+		{fixture: surpricetest.FixtureSupplierUnknownNoError, outcome: models.OutcomeSubmitted, moved: true},
+		{fixture: surpricetest.FixturePoAlreadyExists, outcome: models.OutcomeSubmitted, moved: true},
+		{fixture: surpricetest.FixtureAlreadyProcessed, outcome: models.OutcomeSubmitted, moved: true},
+	}
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			drive := setupRetryTest(t, fixture(t, test.fixture))
+
+			outcome, err := uploadAndHandle(context.Background(), t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outcome != test.outcome {
+				t.Errorf("outcome = %s, want %s", outcome, test.outcome)
+			}
+			movedTo := drive.movedTo(testFolderId)
+			if test.moved && movedTo != testWinsId {
+				t.Errorf("folder moved to %q, want the wins folder", movedTo)
+			}
+			if !test.moved && movedTo != "" {
+				t.Errorf("folder moved to %q, want it left in place", movedTo)
+			}
+		})
+	}
+}
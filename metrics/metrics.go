@@ -0,0 +1,208 @@
+// Package metrics keeps counters and histograms for a running sweep and
+// serves them in the Prometheus text format, so a long sweep can be watched
+// while it runs.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is anything the registry can write out.
+type metric interface {
+	write(w io.Writer)
+}
+
+var registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+func register(m metric) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.metrics = append(registry.metrics, m)
+}
+
+// series is one set of label values of a metric.
+type series struct {
+	labelValues []string
+	value       float64
+	// Histogram series only.
+	bucketCounts []uint64
+	count        uint64
+}
+
+type family struct {
+	mu     sync.Mutex
+	name   string
+	help   string
+	labels []string
+	series map[string]*series
+}
+
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d labels, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		f.series[key] = s
+	}
+	return s
+}
+
+// sorted returns the series ordered by label values so the output is stable.
+func (f *family) sorted() []*series {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := make([]*series, len(keys))
+	for i, key := range keys {
+		list[i] = f.series[key]
+	}
+	return list
+}
+
+// labelString renders the label pairs, with extra appended, as {a="1",b="2"}.
+func (f *family) labelString(labelValues []string, extra ...string) string {
+	var pairs []string
+	for i, label := range f.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, labelEscaper.Replace(labelValues[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelEscaper escapes label values the way the text format expects.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (f *family) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, kind)
+}
+
+type Counter struct {
+	family
+}
+
+// NewCounter registers a counter with the given label names.
+func NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{family{name: name, help: help, labels: labels, series: map[string]*series{}}}
+	register(c)
+	return c
+}
+
+// Inc adds one to the series with labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(value float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(labelValues).value += value
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w, "counter")
+	for _, s := range c.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelString(s.labelValues), formatFloat(s.value))
+	}
+}
+
+type Histogram struct {
+	family
+	buckets []float64
+}
+
+// NewHistogram registers a histogram with the given upper bucket bounds, in
+// ascending order, and label names.
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{family: family{name: name, help: help, labels: labels, series: map[string]*series{}}, buckets: buckets}
+	register(h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(labelValues)
+	if s.bucketCounts == nil {
+		s.bucketCounts = make([]uint64, len(h.buckets))
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.bucketCounts[i]++
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w, "histogram")
+	for _, s := range h.sorted() {
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(s.labelValues, "le", formatFloat(bound)), s.bucketCounts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(s.labelValues), formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(s.labelValues), s.count)
+	}
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Handler serves every registered metric.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		registry.mu.Lock()
+		metrics := append([]metric(nil), registry.metrics...)
+		registry.mu.Unlock()
+		for _, m := range metrics {
+			m.write(w)
+		}
+	})
+}
+
+// Serve starts listening on addr in the background with /metrics mapped to
+// Handler. Errors after start up are printed, since the sweep carries on
+// without its metrics.
+func Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", Handler())
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		if err != nil {
+			log.Println("Error serving metrics")
+			log.Println(err)
+		}
+	}()
+}
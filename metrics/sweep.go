@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The metrics of a sweep.
+var (
+	FoldersProcessed = NewCounter("sweep_folders_processed_total",
+		"Folders the sweep has finished with, by checkpoint outcome.", "outcome")
+	Decisions = NewCounter("sweep_decisions_total",
+		"Actions decided for aged folders from their Insightly opportunity.", "action")
+	DriveParserResults = NewCounter("sweep_drive_parser_results_total",
+		"Drive Parser responses to cost sheet uploads, by result code.", "result")
+	APICalls = NewCounter("sweep_api_calls_total",
+		"HTTP calls to Google, Insightly and Surprice by operation and status. A status of error is a call that got no response.",
+		"api", "method", "status")
+	RateLimited = NewCounter("sweep_rate_limited_total",
+		"Responses with status 429, by API.", "api")
+	PhaseDuration = NewHistogram("sweep_phase_duration_seconds",
+		"How long each phase of handling a folder took.",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}, "phase")
+	Errors = NewCounter("sweep_errors_total",
+		"Failed phases by error category.", "phase", "category")
+)
+
+// Transport counts every request it sends in APICalls, and 429s in
+// RateLimited, under API.
+type Transport struct {
+	// Base sends the requests. http.DefaultTransport is used when nil.
+	Base http.RoundTripper
+	API  string
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			RateLimited.Inc(t.API)
+		}
+	}
+	APICalls.Inc(t.API, Operation(req), status)
+	return resp, err
+}
+
+// Operation names a request by its method and path, with IDs and sheet ranges
+// replaced so every call to the same endpoint shares a label, e.g.
+// "GET /drive/v3/files/{id}".
+func Operation(req *http.Request) string {
+	segments := strings.Split(req.URL.Path, "/")
+	for i, segment := range segments {
+		// Custom methods follow the last colon, as in values:batchUpdate.
+		// Ranges have colons of their own, as in A2:D.
+		name, suffix := segment, ""
+		if colon := strings.LastIndex(segment, ":"); colon >= 0 && isMethod(segment[colon+1:]) {
+			name, suffix = segment[:colon], segment[colon:]
+		}
+		switch {
+		case i > 0 && segments[i-1] == "values" && name != "":
+			name = "{range}"
+		case isId(name):
+			name = "{id}"
+		}
+		segments[i] = name + suffix
+	}
+	return req.Method + " " + strings.Join(segments, "/")
+}
+
+// isId reports whether a path segment is an ID: all digits, as Insightly
+// uses, or long enough to be a Drive file ID.
+func isId(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	if len(segment) >= 20 {
+		return true
+	}
+	for _, r := range segment {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isMethod(name string) bool {
+	if len(name) < 2 || !unicode.IsLower(rune(name[0])) {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
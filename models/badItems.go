@@ -0,0 +1,99 @@
+package models
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const badItemsReportPath = "./json/badItems.csv"
+
+// BadItem is a cost sheet row the Drive Parser left off the PO.
+type BadItem struct {
+	Manufacturer string `json:"manufacturer"`
+	Model        string `json:"model"`
+	Sku          string `json:"sku"`
+	Quantity     string `json:"quantity"`
+	Condition    string `json:"condition"`
+	Reason       string `json:"reason"`
+}
+
+// BadItemHeader is the column row of the CSV report and the Rejected Items
+// tab. BadItem.Row matches it.
+var BadItemHeader = []string{"Manufacturer", "Model", "SKU", "Quantity", "Condition", "Reason"}
+
+func (b BadItem) Row() []string {
+	return []string{b.Manufacturer, b.Model, b.Sku, b.Quantity, b.Condition, b.Reason}
+}
+
+// BadItems lists the rejected items of the response. The server does not say
+// why each item was rejected, so the reason is worked out from what the item
+// is missing, falling back to the response message.
+func (d DriveParserResponse) BadItems() []BadItem {
+	var items []BadItem
+	for _, item := range d.Data.BadItems {
+		sku := ""
+		if item.Sku != nil {
+			sku = strings.TrimSpace(fmt.Sprint(item.Sku))
+		}
+		var missing []string
+		if sku == "" {
+			missing = append(missing, "SKU")
+		}
+		if strings.TrimSpace(item.Quantity) == "" {
+			missing = append(missing, "Quantity")
+		}
+		reason := strings.TrimSpace(d.Message)
+		if len(missing) > 0 {
+			reason = "Missing " + strings.Join(missing, " and ")
+		}
+		items = append(items, BadItem{
+			Manufacturer: item.Manufacturer,
+			Model:        item.Model,
+			Sku:          sku,
+			Quantity:     item.Quantity,
+			Condition:    item.Condition,
+			Reason:       reason,
+		})
+	}
+	return items
+}
+
+// SaveBadItems writes every bad item in the run to ./json/badItems.csv, one
+// row per item with the folder and sheet it came from. A run without any
+// still writes the header, so the rejects of an earlier run are not mistaken
+// for this one's.
+func (r *RunReport) SaveBadItems() (int, error) {
+	count := 0
+	for _, folder := range r.Folders {
+		count += len(folder.BadItems)
+	}
+	file, err := os.Create(badItemsReportPath)
+	if err != nil {
+		log.Println("Error creating file")
+		return 0, err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Println("Error closing file")
+		}
+	}(file)
+	writer := csv.NewWriter(file)
+	err = writer.Write(append([]string{"Folder", "Sheet"}, BadItemHeader...))
+	if err != nil {
+		return 0, err
+	}
+	for _, folder := range r.Folders {
+		for _, item := range folder.BadItems {
+			err = writer.Write(append([]string{folder.FolderName, folder.SheetName}, item.Row()...))
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	writer.Flush()
+	return count, writer.Error()
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+const checkpointPath = "./json/checkpoint.json"
+
+// Outcomes recorded against a folder in the checkpoint.
+const (
+	OutcomeNoSheet   = "no-sheet"
+	OutcomeSkipped   = "skipped"
+	OutcomeSubmitted = "submitted"
+	OutcomePoCreated = "po-created"
+	OutcomeRejected  = "rejected"
+	OutcomeError     = "error"
+)
+
+type CheckpointEntry struct {
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Checkpoint is the record of which procurement folders a sweep has already
+// handled, so an interrupted run can be resumed instead of started over.
+type Checkpoint struct {
+	Start     time.Time                  `json:"start"`
+	Completed bool                       `json:"completed"`
+	Folders   map[string]CheckpointEntry `json:"folders"`
+}
+
+func (c *Checkpoint) GetCheckpoint() error {
+	exists := os.IsExist(os.Mkdir("./json", 0755))
+	if exists {
+		log.Println("Json folder exists")
+	}
+	file, err := os.Open(checkpointPath)
+	if errors.Is(err, os.ErrNotExist) {
+		c.Reset(time.Now())
+		return nil
+	}
+	if err != nil {
+		log.Println("Error opening checkpoint")
+		return err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Println("Error closing file")
+		}
+	}(file)
+
+	err = json.NewDecoder(file).Decode(c)
+	if err != nil {
+		log.Println("Error decoding checkpoint")
+		return err
+	}
+	if c.Folders == nil {
+		c.Folders = map[string]CheckpointEntry{}
+	}
+	return nil
+}
+
+// Reset discards every recorded folder and starts a new run at start.
+func (c *Checkpoint) Reset(start time.Time) {
+	c.Start = start
+	c.Completed = false
+	c.Folders = map[string]CheckpointEntry{}
+}
+
+// IsHandled reports whether the folder was dealt with in the checkpointed run.
+// Folders that errored are not considered handled so they get re-checked.
+func (c *Checkpoint) IsHandled(folderId string) bool {
+	entry, ok := c.Folders[folderId]
+	if !ok {
+		return false
+	}
+	return entry.Outcome != OutcomeError
+}
+
+// Handled returns a snapshot of the folders IsHandled would skip, safe to read
+// while the checkpoint keeps being recorded to.
+func (c *Checkpoint) Handled() map[string]bool {
+	handled := map[string]bool{}
+	for folderId := range c.Folders {
+		if c.IsHandled(folderId) {
+			handled[folderId] = true
+		}
+	}
+	return handled
+}
+
+func (c *Checkpoint) Record(folderId string, outcome string, err error) {
+	entry := CheckpointEntry{Outcome: outcome, Timestamp: time.Now()}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	c.Folders[folderId] = entry
+}
+
+// Save writes the checkpoint to a temporary file and renames it into place so
+// a run killed mid-write never leaves a truncated checkpoint behind.
+func (c *Checkpoint) Save() error {
+	tmpPath := checkpointPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		log.Println("Error creating file")
+		return err
+	}
+	err = json.NewEncoder(file).Encode(c)
+	if err != nil {
+		log.Println("Error saving json")
+		_ = file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		log.Println("Error closing file")
+		return err
+	}
+	return os.Rename(tmpPath, checkpointPath)
+}
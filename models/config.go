@@ -2,12 +2,67 @@ package models
 
 import (
 	"encoding/json"
-	"fmt"
+	"log"
 	"os"
+	"path/filepath"
 )
 
+type ConcurrencyConfig struct {
+	// Enumerate is the number of workers listing files inside folders.
+	Enumerate int `json:"enumerate"`
+	// Process is the number of folders decided and acted on at once.
+	Process int `json:"process"`
+}
+
+// RateLimitConfig caps calls per minute to each API, shared by all workers.
+// Zero means unlimited.
+type RateLimitConfig struct {
+	DrivePerMinute       int `json:"drivePerMinute"`
+	SheetsPerMinute      int `json:"sheetsPerMinute"`
+	DriveParserPerMinute int `json:"driveParserPerMinute"`
+}
+
+type InsightlyConfig struct {
+	RequestsPerSecond int `json:"requestsPerSecond"`
+	// DailyLimit is the number of calls allowed per UTC day. Zero is unlimited.
+	DailyLimit    int `json:"dailyLimit"`
+	CacheTTLHours int `json:"cacheTtlHours"`
+	// ReportFields are the opportunity custom fields shown in logs and the
+	// run report.
+	ReportFields []CustomFieldSpec `json:"reportFields"`
+	WriteBack    WriteBackConfig   `json:"writeBack"`
+}
+
+// ServeConfig is used by serve mode.
+type ServeConfig struct {
+	// Schedule is a cron expression, e.g. "0 6 * * 1-5". Without one runs
+	// only start from the /run endpoint.
+	Schedule string `json:"schedule"`
+	Addr     string `json:"addr"`
+}
+
 type ConfigJson struct {
-	SleepTimeOut int `json:"sleepTimeOut" default:"2"`
+	SleepTimeOut int               `json:"sleepTimeOut" default:"2"`
+	Concurrency  ConcurrencyConfig `json:"concurrency"`
+	RateLimits   RateLimitConfig   `json:"rateLimits"`
+	Insightly    InsightlyConfig   `json:"insightly"`
+	Tracing      TracingConfig     `json:"tracing"`
+	Serve        ServeConfig       `json:"serve"`
+	// OpportunityRules decide what happens to aged folders with no cost. The
+	// first matching rule wins.
+	OpportunityRules []OpportunityRule `json:"opportunityRules"`
+}
+
+func defaultConfig() ConfigJson {
+	return ConfigJson{
+		SleepTimeOut:     2,
+		Concurrency:      ConcurrencyConfig{Enumerate: 10, Process: 1},
+		RateLimits:       RateLimitConfig{SheetsPerMinute: 60},
+		Insightly:        InsightlyConfig{RequestsPerSecond: 5, CacheTTLHours: 24},
+		Tracing:          TracingConfig{File: "./json/traces.json", ServiceName: "drive-parser"},
+		Serve:            ServeConfig{Addr: ":8080"},
+		OpportunityRules: DefaultOpportunityRules(),
+	}
 }
 
 func createFileIfNotExists(path string) {
@@ -15,39 +70,87 @@ func createFileIfNotExists(path string) {
 	if err != nil {
 		file, err := os.Create(path)
 		if err != nil {
-			fmt.Println("Error creating file")
+			log.Println("Error creating file")
 			panic(err)
 		}
-		emptyData := ConfigJson{SleepTimeOut: 2}
+		emptyData := defaultConfig()
 		jsonParser := json.NewEncoder(file)
 		err = jsonParser.Encode(emptyData)
 		if err != nil {
-			fmt.Println("Error saving json")
+			log.Println("Error saving json")
 			panic(err)
 		}
 	}
 }
 
-func (c *ConfigJson) GetConfig() error {
-	exists := os.IsExist(os.Mkdir("./json", 0755))
+// DefaultConfigPath is where the config is read from unless told otherwise.
+const DefaultConfigPath = "./json/config.json"
+
+// GetConfig reads the config at path, writing the defaults there first when
+// it does not exist yet.
+func (c *ConfigJson) GetConfig(path string) error {
+	exists := os.IsExist(os.MkdirAll(filepath.Dir(path), 0755))
 	if exists {
-		fmt.Println("Json folder exists")
+		log.Println("Json folder exists")
+	}
+	createFileIfNotExists(path)
+	file, err := os.Open(path)
+	if err != nil {
+		log.Println("Error opening config")
+		return err
 	}
-	createFileIfNotExists("./json/config.json")
-	file, err := os.Open("./json/config.json")
 	defer func(file *os.File) {
 		err := file.Close()
 		if err != nil {
-			fmt.Println("Error closing file")
+			log.Println("Error closing file")
 			panic(err)
 		}
 	}(file)
 
+	// Decode over the defaults so older config files without the newer
+	// sections keep working.
+	// Rules are decoded onto nothing so a rule from the file never inherits
+	// fields from the default rule it happens to replace.
+	*c = defaultConfig()
+	c.OpportunityRules = nil
 	jsonParser := json.NewDecoder(file)
 	err = jsonParser.Decode(c)
 	if err != nil {
-		fmt.Println("Error decoding json")
+		log.Println("Error decoding json")
 		return err
 	}
+	if c.OpportunityRules == nil {
+		c.OpportunityRules = defaultConfig().OpportunityRules
+	}
+	if c.Concurrency.Enumerate < 1 {
+		c.Concurrency.Enumerate = 1
+	}
+	if c.Concurrency.Process < 1 {
+		c.Concurrency.Process = 1
+	}
+	for _, field := range c.Insightly.ReportFields {
+		err = field.validate()
+		if err != nil {
+			log.Println("Error in Insightly report fields")
+			return err
+		}
+	}
+	err = c.Insightly.WriteBack.validate()
+	if err != nil {
+		log.Println("Error in Insightly write back")
+		return err
+	}
+	err = c.Tracing.validate()
+	if err != nil {
+		log.Println("Error in tracing config")
+		return err
+	}
+	for _, rule := range c.OpportunityRules {
+		err = rule.validate()
+		if err != nil {
+			log.Println("Error in opportunity rules")
+			return err
+		}
+	}
 	return nil
 }
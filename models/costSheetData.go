@@ -1,7 +1,7 @@
 package models
 
 import (
-	"fmt"
+	"log"
 	"math"
 	"strconv"
 	"strings"
@@ -38,7 +38,7 @@ OUTER:
 		// Condition
 		condition, err := strconv.Atoi(row[3].(string))
 		if err != nil {
-			fmt.Println("Error converting condition to int")
+			log.Println("Error converting condition to int")
 			continue
 		}
 		newRow.Condition = condition
@@ -46,7 +46,7 @@ OUTER:
 		// Ebay
 		ebay, err := strconv.Atoi(row[6].(string))
 		if err != nil {
-			fmt.Println("Error converting ebay to int")
+			log.Println("Error converting ebay to int")
 			continue
 		}
 		newRow.Ebay = ebay
@@ -57,7 +57,7 @@ OUTER:
 		// AP
 		ap, err := strconv.ParseBool(row[9].(string))
 		if err != nil {
-			fmt.Println("Error converting ap to bool")
+			log.Println("Error converting ap to bool")
 			continue
 		}
 		newRow.AP = ap
@@ -72,9 +72,9 @@ OUTER:
 		if row[11] != nil && row[11] != "" {
 			inv, err := strconv.Atoi(row[11].(string))
 			if err != nil {
-				fmt.Println("Error converting inv to int")
-				fmt.Println("Received: ")
-				fmt.Println(row[11].(string))
+				log.Println("Error converting inv to int")
+				log.Println("Received: ")
+				log.Println(row[11].(string))
 				continue
 			}
 			newRow.Inv = inv
@@ -94,7 +94,7 @@ OUTER:
 		costSentToSVFloat = costSentToSVFloat * 100
 		costSentToSVInt := int(math.Round(costSentToSVFloat)) / 100
 		if err != nil {
-			fmt.Println("Error converting cost sent to sv to int")
+			log.Println("Error converting cost sent to sv to int")
 			continue
 		}
 		newRow.CostSentToSV = costSentToSVInt
@@ -102,7 +102,7 @@ OUTER:
 		// check to see if a row with the same sku already exists
 		for _, existingRow := range c.FormattedRows {
 			if newRow.Sku == existingRow.Sku {
-				fmt.Println("Duplicate Sku: ", newRow.Sku)
+				log.Println("Duplicate Sku: ", newRow.Sku)
 				continue OUTER
 			}
 		}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Custom field types that can be declared in config.
+const (
+	CustomFieldTypeString   = "string"
+	CustomFieldTypeNumber   = "number"
+	CustomFieldTypeDate     = "date"
+	CustomFieldTypeBool     = "bool"
+	CustomFieldTypeDropdown = "dropdown"
+)
+
+// customFieldDateFormat is how Insightly writes date custom fields.
+const customFieldDateFormat = "2006-01-02 15:04:05"
+
+// CustomFieldSpec declares a custom field to surface in the run report.
+type CustomFieldSpec struct {
+	// Name is the Insightly FIELD_NAME, e.g. "Expected_Close_Date__c".
+	Name string `json:"name"`
+	// Label is the key used in the report. Name is used when empty.
+	Label string `json:"label,omitempty"`
+	Type  string `json:"type"`
+}
+
+func (c CustomFieldSpec) key() string {
+	if c.Label != "" {
+		return c.Label
+	}
+	return c.Name
+}
+
+func (c CustomFieldSpec) validate() error {
+	switch c.Type {
+	case CustomFieldTypeString, CustomFieldTypeNumber, CustomFieldTypeDate, CustomFieldTypeBool, CustomFieldTypeDropdown:
+		return nil
+	}
+	return fmt.Errorf("unknown type %q for custom field %s", c.Type, c.Name)
+}
+
+// CustomField returns the raw value of the named custom field. ok is false
+// when the opportunity does not have the field or it is empty.
+func (i *InsightlyData) CustomField(name string) (value interface{}, ok bool) {
+	for _, field := range i.CustomFields {
+		if field.FieldName == name {
+			return field.FieldValue, field.FieldValue != nil
+		}
+	}
+	return nil, false
+}
+
+func (i *InsightlyData) CustomFieldString(name string) (string, bool) {
+	value, ok := i.CustomField(name)
+	if !ok {
+		return "", false
+	}
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return fmt.Sprint(value), true
+}
+
+// CustomFieldDropdown returns the selected option of a dropdown field.
+func (i *InsightlyData) CustomFieldDropdown(name string) (string, bool) {
+	value, ok := i.CustomField(name)
+	if !ok {
+		return "", false
+	}
+	option, ok := value.(string)
+	return option, ok
+}
+
+func (i *InsightlyData) CustomFieldNumber(name string) (float64, bool) {
+	value, ok := i.CustomField(name)
+	if !ok {
+		return 0, false
+	}
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+func (i *InsightlyData) CustomFieldDate(name string) (time.Time, bool) {
+	value, ok := i.CustomField(name)
+	if !ok {
+		return time.Time{}, false
+	}
+	text, ok := value.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(customFieldDateFormat, text)
+	if err != nil {
+		date, err = time.Parse(time.DateOnly, text)
+	}
+	return date, err == nil
+}
+
+func (i *InsightlyData) CustomFieldBool(name string) (bool, bool) {
+	value, ok := i.CustomField(name)
+	if !ok {
+		return false, false
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// CustomFieldValue formats the field as spec.Type for reports and rules.
+// Dates are written as 2006-01-02.
+func (i *InsightlyData) CustomFieldValue(spec CustomFieldSpec) (string, bool) {
+	switch spec.Type {
+	case CustomFieldTypeNumber:
+		number, ok := i.CustomFieldNumber(spec.Name)
+		return strconv.FormatFloat(number, 'f', -1, 64), ok
+	case CustomFieldTypeDate:
+		date, ok := i.CustomFieldDate(spec.Name)
+		return date.Format(time.DateOnly), ok
+	case CustomFieldTypeBool:
+		b, ok := i.CustomFieldBool(spec.Name)
+		return strconv.FormatBool(b), ok
+	case CustomFieldTypeDropdown:
+		return i.CustomFieldDropdown(spec.Name)
+	}
+	return i.CustomFieldString(spec.Name)
+}
+
+// ReportCustomFields returns the declared fields the opportunity has, keyed by
+// their report label.
+func (i *InsightlyData) ReportCustomFields(specs []CustomFieldSpec) map[string]string {
+	fields := map[string]string{}
+	for _, spec := range specs {
+		value, ok := i.CustomFieldValue(spec)
+		if ok {
+			fields[spec.key()] = value
+		}
+	}
+	return fields
+}
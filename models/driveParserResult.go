@@ -0,0 +1,58 @@
+package models
+
+import (
+	"strings"
+)
+
+// DriveParserResult is what the Drive Parser did with a cost sheet.
+type DriveParserResult string
+
+const (
+	ResultPoCreated          DriveParserResult = "PO_CREATED"
+	ResultPoAlreadyExists    DriveParserResult = "PO_ALREADY_EXISTS"
+	ResultAlreadyProcessed   DriveParserResult = "ALREADY_PROCESSED"
+	ResultSupplierUnknown    DriveParserResult = "SUPPLIER_UNKNOWN"
+	ResultItemsSkipped       DriveParserResult = "ITEMS_SKIPPED"
+	ResultUpstreamBadGateway DriveParserResult = "UPSTREAM_BAD_GATEWAY"
+	// ResultAccepted and ResultRejected stand in for any other response,
+	// going by its error flag.
+	ResultAccepted DriveParserResult = "ACCEPTED"
+	ResultRejected DriveParserResult = "REJECTED"
+)
+
+var driveParserResults = map[DriveParserResult]bool{
+	ResultPoCreated:          true,
+	ResultPoAlreadyExists:    true,
+	ResultAlreadyProcessed:   true,
+	ResultSupplierUnknown:    true,
+	ResultItemsSkipped:       true,
+	ResultUpstreamBadGateway: true,
+	ResultAccepted:           true,
+	ResultRejected:           true,
+}
+
+// driveParserMessages maps the messages of servers that do not send a code
+// yet to the code they mean.
+var driveParserMessages = map[string]DriveParserResult{
+	"PO Created Successfully":                                     ResultPoCreated,
+	"PO Already Exists":                                           ResultPoAlreadyExists,
+	"Sheet has already been processed":                            ResultAlreadyProcessed,
+	"Supplier Name could not be determined":                       ResultSupplierUnknown,
+	"Some items were skipped because they had no SKU or Quantity": ResultItemsSkipped,
+	"Error updating sheet: Request failed with status code 502":   ResultUpstreamBadGateway,
+}
+
+// Result returns the response's code, falling back to its message when the
+// server sent no code or one this version does not know.
+func (d DriveParserResponse) Result() DriveParserResult {
+	if driveParserResults[d.Code] {
+		return d.Code
+	}
+	if result, ok := driveParserMessages[strings.TrimSpace(d.Message)]; ok {
+		return result
+	}
+	if d.Error {
+		return ResultRejected
+	}
+	return ResultAccepted
+}
@@ -1,44 +1,14 @@
 package models
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"time"
+	"strings"
 )
 
-const getOpportunityURL = "https://api.insightly.com/v3.1/Opportunities/"
-
-type AbandonedOppType struct {
-	State string
-	name  string
-}
-
-// Opp ID:  40784214
-//Opp State:  ABANDONED
-//Opp Pipeline ID:  1055391
-//Opp Stage ID:  4380676
-
-var abandonedOppTypes = []AbandonedOppType{
-	{State: "ABANDONED", name: "Abandoned"},
-	{State: "LOST", name: "Lost"},
-}
-var suspendedOppTypes = []AbandonedOppType{
-	{State: "SUSPENDED", name: "Suspended"},
-}
-var WonOppTypes = []AbandonedOppType{
-	{State: "WON", name: "Won"},
-}
-var OpenOppTypes = []AbandonedOppType{
-	{State: "OPEN", name: "Open"},
-}
-
 type CustomField struct {
 	FieldName     string      `json:"FIELD_NAME"`
 	FieldValue    interface{} `json:"FIELD_VALUE"`
-	CustomFieldId string      `json:"CUSTOM_FIELD_ID"`
+	CustomFieldId string      `json:"CUSTOM_FIELD_ID,omitempty"`
 }
 
 type Link struct {
@@ -51,6 +21,11 @@ type Link struct {
 	LinkObjectId   int    `json:"LINK_OBJECT_ID"`
 }
 
+// Opp ID:  40784214
+//Opp State:  ABANDONED
+//Opp Pipeline ID:  1055391
+//Opp Stage ID:  4380676
+
 type InsightlyData struct {
 	OpportunityId      int           `json:"OPPORTUNITY_ID"`
 	OpportunityName    string        `json:"OPPORTUNITY_NAME"`
@@ -66,74 +41,57 @@ type InsightlyData struct {
 	StageId            int           `json:"STAGE_ID"`
 	CustomFields       []CustomField `json:"CUSTOMFIELDS"`
 	Links              []Link        `json:"LINKS"`
+
+	// PipelineName and StageName are resolved from PipelineId and StageId,
+	// they are not part of the opportunity Insightly returns.
+	PipelineName string `json:"-"`
+	StageName    string `json:"-"`
+	// Owner is resolved from ResponsibleUserId.
+	Owner OpportunityOwner `json:"-"`
 }
 
-func (i *InsightlyData) GetOpportunity(OpportunityId string) (string, error) {
-	myClient := &http.Client{Timeout: 60 * time.Second}
-	if os.Getenv("INSIGHTLY_API_KEY") == "" {
-		return "No API Key found", fmt.Errorf("No API Key found")
-	}
-	AuthorizationHeader := "Basic " + os.Getenv("INSIGHTLY_API_KEY")
-	// Call the endpoint URL with an Authorization header
-	req, err := http.NewRequest("GET", getOpportunityURL+OpportunityId, nil)
-	if err != nil {
-		return err.Error(), err
-	}
-	req.Header.Add("Authorization", AuthorizationHeader)
-	resp, err := myClient.Do(req)
-	if err != nil {
-		return err.Error(), err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("Error closing body")
-		}
-	}(resp.Body)
+type InsightlyPipeline struct {
+	PipelineId   int    `json:"PIPELINE_ID"`
+	PipelineName string `json:"PIPELINE_NAME"`
+}
 
-	// Decode the response into the InsightlyData struct
-	err = json.NewDecoder(resp.Body).Decode(&i)
-	if err != nil {
-		fmt.Println("Error decoding response")
-		fmt.Println("Expected an InsightlyData struct")
-		fmt.Println("Got: ", resp.Body)
-		return err.Error(), err
-	}
-	return "Successfully retrieved opp", nil
+type InsightlyPipelineStage struct {
+	StageId    int    `json:"STAGE_ID"`
+	PipelineId int    `json:"PIPELINE_ID"`
+	StageName  string `json:"STAGE_NAME"`
+	StageOrder int    `json:"STAGE_ORDER"`
 }
 
-func (i *InsightlyData) IsAbandoned() bool {
-	for _, v := range abandonedOppTypes {
-		if i.OpportunityState == v.State {
-			return true
-		}
-	}
-	return false
+type InsightlyNote struct {
+	NoteId int    `json:"NOTE_ID,omitempty"`
+	Title  string `json:"TITLE"`
+	Body   string `json:"BODY"`
 }
 
-func (i *InsightlyData) IsSuspended() bool {
-	for _, v := range suspendedOppTypes {
-		if i.OpportunityState == v.State {
-			return true
-		}
-	}
-	return false
+type InsightlyUser struct {
+	UserId       int    `json:"USER_ID"`
+	FirstName    string `json:"FIRST_NAME"`
+	LastName     string `json:"LAST_NAME"`
+	EmailAddress string `json:"EMAIL_ADDRESS"`
 }
 
-func (i *InsightlyData) IsWon() bool {
-	for _, v := range WonOppTypes {
-		if i.OpportunityState == v.State {
-			return true
-		}
-	}
-	return false
+func (u InsightlyUser) Name() string {
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+// OpportunityOwner is who to contact about an opportunity. Both fields are
+// empty when the owner could not be found.
+type OpportunityOwner struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
 }
 
-func (i *InsightlyData) IsOpen() bool {
-	for _, v := range OpenOppTypes {
-		if i.OpportunityState == v.State {
-			return true
-		}
+func (o OpportunityOwner) String() string {
+	switch {
+	case o.Name != "" && o.Email != "":
+		return fmt.Sprintf("%s <%s>", o.Name, o.Email)
+	case o.Name != "":
+		return o.Name
 	}
-	return false
+	return o.Email
 }
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpportunityAction is what the sweep does with an aged folder that has no
+// cost, based on its Insightly opportunity.
+type OpportunityAction string
+
+const (
+	ActionMoveToLosses  OpportunityAction = "move-to-losses"
+	ActionMoveToWins    OpportunityAction = "move-to-wins"
+	ActionMarkSuspended OpportunityAction = "mark-suspended"
+	ActionMarkForgotten OpportunityAction = "mark-forgotten"
+	ActionNotifyOnly    OpportunityAction = "notify-only"
+	ActionNone          OpportunityAction = "no-op"
+)
+
+var opportunityActions = []OpportunityAction{
+	ActionMoveToLosses,
+	ActionMoveToWins,
+	ActionMarkSuspended,
+	ActionMarkForgotten,
+	ActionNotifyOnly,
+	ActionNone,
+}
+
+// StateNotFound is the state rules use to match an opportunity Insightly
+// confirmed does not exist.
+const StateNotFound = "NOT_FOUND"
+
+// OpportunityRule maps an opportunity to an action. Empty or zero fields match
+// anything, so a rule with only State set covers every pipeline and stage.
+// Pipelines and stages can be targeted by ID or, case-insensitively, by name
+// such as "Awaiting PO".
+type OpportunityRule struct {
+	State        string `json:"state"`
+	PipelineId   int    `json:"pipelineId,omitempty"`
+	PipelineName string `json:"pipelineName,omitempty"`
+	StageId      int    `json:"stageId,omitempty"`
+	StageName    string `json:"stageName,omitempty"`
+	// CustomFields must all match for the rule to apply.
+	CustomFields []CustomFieldCondition `json:"customFields,omitempty"`
+	Action       OpportunityAction      `json:"action"`
+}
+
+// CustomFieldCondition matches when the named custom field, formatted as Type,
+// equals Equals ignoring case. Dates compare as 2006-01-02.
+type CustomFieldCondition struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Equals string `json:"equals"`
+}
+
+func (c CustomFieldCondition) Matches(opportunity *InsightlyData) bool {
+	value, ok := opportunity.CustomFieldValue(CustomFieldSpec{Name: c.Name, Type: c.Type})
+	return ok && strings.EqualFold(value, c.Equals)
+}
+
+// defaultOpportunityRules is the policy the sweep has always followed.
+var defaultOpportunityRules = []OpportunityRule{
+	{State: StateNotFound, Action: ActionMoveToLosses},
+	{State: "ABANDONED", Action: ActionMoveToLosses},
+	{State: "LOST", Action: ActionMoveToLosses},
+	{State: "WON", Action: ActionMoveToWins},
+	{State: "SUSPENDED", Action: ActionMarkSuspended},
+	{State: "OPEN", Action: ActionMarkForgotten},
+}
+
+// DefaultOpportunityRules returns a copy of the rules used when the config
+// sets none.
+func DefaultOpportunityRules() []OpportunityRule {
+	return append([]OpportunityRule(nil), defaultOpportunityRules...)
+}
+
+func (r OpportunityRule) Matches(state string, opportunity *InsightlyData) bool {
+	if r.State != "" && r.State != state {
+		return false
+	}
+	if opportunity == nil {
+		return r.PipelineId == 0 && r.StageId == 0 && r.PipelineName == "" && r.StageName == "" && len(r.CustomFields) == 0
+	}
+	if r.PipelineId != 0 && r.PipelineId != opportunity.PipelineId {
+		return false
+	}
+	if r.PipelineName != "" && !strings.EqualFold(r.PipelineName, opportunity.PipelineName) {
+		return false
+	}
+	if r.StageId != 0 && r.StageId != opportunity.StageId {
+		return false
+	}
+	if r.StageName != "" && !strings.EqualFold(r.StageName, opportunity.StageName) {
+		return false
+	}
+	for _, condition := range r.CustomFields {
+		if !condition.Matches(opportunity) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r OpportunityRule) validate() error {
+	for _, condition := range r.CustomFields {
+		err := CustomFieldSpec{Name: condition.Name, Type: condition.Type}.validate()
+		if err != nil {
+			return err
+		}
+	}
+	for _, action := range opportunityActions {
+		if r.Action == action {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown action %q for opportunity rule %+v", r.Action, r)
+}
+
+// MatchOpportunityRule returns the action of the first rule matching the
+// opportunity, so more specific rules belong ahead of general ones. A nil
+// opportunity is matched as StateNotFound. No match is ActionNone.
+func MatchOpportunityRule(rules []OpportunityRule, opportunity *InsightlyData) (OpportunityRule, bool) {
+	state := StateNotFound
+	if opportunity != nil {
+		state = opportunity.OpportunityState
+	}
+	for _, rule := range rules {
+		if rule.Matches(state, opportunity) {
+			return rule, true
+		}
+	}
+	return OpportunityRule{State: state, Action: ActionNone}, false
+}
@@ -2,7 +2,7 @@ package models
 
 import (
 	"encoding/json"
-	"fmt"
+	"log"
 	"os"
 )
 
@@ -15,7 +15,7 @@ func (p *ParsedDrivesJson) GetDrives() {
 	// check if json folder exists
 	exists := os.IsExist(os.Mkdir("./json", 0755))
 	if exists {
-		fmt.Println("Json folder exists")
+		log.Println("Json folder exists")
 	}
 	// check if parsedFiles.json exists
 	_, err := os.Stat("./json/parsedFiles.json")
@@ -23,13 +23,13 @@ func (p *ParsedDrivesJson) GetDrives() {
 		// create the file { "drives": [] }
 		file, err := os.Create("./json/parsedFiles.json")
 		if err != nil {
-			fmt.Println("Error creating file")
+			log.Println("Error creating file")
 			panic(err)
 		}
 		defer func(file *os.File) {
 			err := file.Close()
 			if err != nil {
-				fmt.Println("Error closing file")
+				log.Println("Error closing file")
 				panic(err)
 			}
 		}(file)
@@ -37,20 +37,20 @@ func (p *ParsedDrivesJson) GetDrives() {
 		jsonParser := json.NewEncoder(file)
 		err = jsonParser.Encode(emptyData)
 		if err != nil {
-			fmt.Println("Error saving json")
+			log.Println("Error saving json")
 			panic(err)
 		}
 	}
 
 	file, err := os.Open("./json/parsedFiles.json")
 	if err != nil {
-		fmt.Println("Error opening file")
+		log.Println("Error opening file")
 		panic(err)
 	}
 	defer func(file *os.File) {
 		err := file.Close()
 		if err != nil {
-			fmt.Println("Error closing file")
+			log.Println("Error closing file")
 			panic(err)
 		}
 	}(file)
@@ -58,7 +58,7 @@ func (p *ParsedDrivesJson) GetDrives() {
 	jsonParser := json.NewDecoder(file)
 	err = jsonParser.Decode(p)
 	if err != nil {
-		fmt.Println("Error parsing json")
+		log.Println("Error parsing json")
 		panic(err)
 	}
 }
@@ -93,20 +93,20 @@ func (p *ParsedDrivesJson) RemoveCostSheetNotSubmitted(drive string) {
 func (p *ParsedDrivesJson) SaveDrives() {
 	file, err := os.Create("./json/parsedFiles.json")
 	if err != nil {
-		fmt.Println("Error creating file")
+		log.Println("Error creating file")
 		panic(err)
 	}
 	defer func(file *os.File) {
 		err := file.Close()
 		if err != nil {
-			fmt.Println("Error closing file")
+			log.Println("Error closing file")
 			panic(err)
 		}
 	}(file)
 	jsonParser := json.NewEncoder(file)
 	err = jsonParser.Encode(p)
 	if err != nil {
-		fmt.Println("Error saving json")
+		log.Println("Error saving json")
 		panic(err)
 	}
 }
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"google.golang.org/api/sheets/v4"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	AcceptedOfferRange = "Final Offer!T3"
+	OfferRowsRange     = "Final Offer!A2:D"
+)
+
+// PricingSheetFields is the field mask for reading a PricingSheet with a
+// single Spreadsheets.Get over AcceptedOfferRange and OfferRowsRange.
+const PricingSheetFields = "properties.title,sheets.data(startRow,startColumn,rowData.values.formattedValue)"
+
+// PricingSheet is everything the sweep reads from a pricing sheet.
+type PricingSheet struct {
+	Id            string
+	Title         string
+	AcceptedOffer string
+	OfferRows     [][]interface{}
+}
+
+// NewPricingSheet builds a PricingSheet from a Spreadsheets.Get response made
+// with IncludeGridData over AcceptedOfferRange then OfferRowsRange.
+func NewPricingSheet(sheetID string, spreadsheet *sheets.Spreadsheet) (*PricingSheet, error) {
+	p := &PricingSheet{Id: sheetID}
+	if spreadsheet.Properties != nil {
+		p.Title = spreadsheet.Properties.Title
+	}
+	if len(spreadsheet.Sheets) < 1 || len(spreadsheet.Sheets[0].Data) < 2 {
+		return nil, fmt.Errorf("pricing sheet %s is missing the Final Offer ranges", sheetID)
+	}
+	data := spreadsheet.Sheets[0].Data
+
+	acceptedOffer := gridValues(data[0])
+	if len(acceptedOffer) > 0 && len(acceptedOffer[0]) > 0 {
+		p.AcceptedOffer = acceptedOffer[0][0].(string)
+	}
+	p.OfferRows = gridValues(data[1])
+	return p, nil
+}
+
+// gridValues flattens grid data to the shape Values.Get returns: formatted
+// strings with trailing empty cells and rows dropped.
+func gridValues(grid *sheets.GridData) [][]interface{} {
+	var rows [][]interface{}
+	lastRow := -1
+	for i, rowData := range grid.RowData {
+		row := []interface{}{}
+		lastCell := -1
+		for j, cell := range rowData.Values {
+			if cell.FormattedValue != "" {
+				lastCell = j
+			}
+		}
+		for _, cell := range rowData.Values[:lastCell+1] {
+			row = append(row, cell.FormattedValue)
+		}
+		if len(row) > 0 {
+			lastRow = i
+		}
+		rows = append(rows, row)
+	}
+	return rows[:lastRow+1]
+}
+
+// Cost parses the accepted offer, e.g. "$1,250", into whole dollars. hasCost
+// is false when no offer has been entered.
+func (p *PricingSheet) Cost() (cost int, hasCost bool, err error) {
+	if p.AcceptedOffer == "" {
+		return 0, false, nil
+	}
+	currencyStr := strings.TrimSpace(p.AcceptedOffer)
+	currencyStr = strings.Replace(currencyStr, "$", "", -1)
+	currencyStr = strings.Replace(currencyStr, ",", "", -1)
+
+	cost, err = strconv.Atoi(currencyStr)
+	if err != nil {
+		log.Println("Error converting currency string to int")
+		return 0, false, err
+	}
+	return cost, true, nil
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+const runReportPath = "./json/report.json"
+
+// FolderReport is what a sweep did with one procurement folder.
+type FolderReport struct {
+	FolderId   string `json:"folderId"`
+	FolderName string `json:"folderName"`
+	SheetName  string `json:"sheetName,omitempty"`
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+
+	OpportunityId    string `json:"opportunityId,omitempty"`
+	OpportunityState string `json:"opportunityState,omitempty"`
+	Pipeline         string `json:"pipeline,omitempty"`
+	Stage            string `json:"stage,omitempty"`
+	Action           string `json:"action,omitempty"`
+	OwnerName        string `json:"ownerName,omitempty"`
+	OwnerEmail       string `json:"ownerEmail,omitempty"`
+
+	CustomFields map[string]string `json:"customFields,omitempty"`
+	// InsightlyUpdates lists what was written back to the opportunity, or
+	// would have been on a dry run.
+	InsightlyUpdates []string `json:"insightlyUpdates,omitempty"`
+
+	PoNumber         string `json:"poNumber,omitempty"`
+	PoResponseStatus string `json:"poResponseStatus,omitempty"`
+	// BadItems are the cost sheet rows the Drive Parser rejected.
+	BadItems []BadItem `json:"badItems,omitempty"`
+}
+
+// SetOpportunity records the Insightly side of the decision made for the
+// folder. A nil opportunity is one Insightly does not know about.
+func (f *FolderReport) SetOpportunity(opportunity *InsightlyData, action OpportunityAction) {
+	f.Action = string(action)
+	if opportunity == nil {
+		f.OpportunityState = StateNotFound
+		return
+	}
+	f.OpportunityState = opportunity.OpportunityState
+	f.Pipeline = opportunity.PipelineName
+	f.Stage = opportunity.StageName
+	f.OwnerName = opportunity.Owner.Name
+	f.OwnerEmail = opportunity.Owner.Email
+}
+
+// RunReport is the per-folder account of a sweep, written to ./json/report.json
+// when the sweep ends.
+type RunReport struct {
+	Start   time.Time      `json:"start"`
+	End     time.Time      `json:"end"`
+	Folders []FolderReport `json:"folders"`
+}
+
+func (r *RunReport) Add(folder FolderReport) {
+	r.Folders = append(r.Folders, folder)
+}
+
+func (r *RunReport) Save() error {
+	exists := os.IsExist(os.Mkdir("./json", 0755))
+	if exists {
+		log.Println("Json folder exists")
+	}
+	// Written aside and renamed into place so GET /report never reads a
+	// half written file.
+	tmpPath := runReportPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		log.Println("Error creating file")
+		return err
+	}
+	jsonParser := json.NewEncoder(file)
+	jsonParser.SetIndent("", "  ")
+	err = jsonParser.Encode(r)
+	if err != nil {
+		log.Println("Error saving json")
+		_ = file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		log.Println("Error closing file")
+		return err
+	}
+	return os.Rename(tmpPath, runReportPath)
+}
+
+// LastRunReport reads the report saved by the last finished sweep. It returns
+// nil when no sweep has finished yet.
+func LastRunReport() (*RunReport, error) {
+	file, err := os.Open(runReportPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var report RunReport
+	err = json.NewDecoder(file).Decode(&report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
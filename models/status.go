@@ -2,82 +2,96 @@ package models
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
 	"os"
+	"time"
 )
 
+const statusPath = "./json/status.json"
+
+// Status is whether a sweep is running and how the last one ended, kept in
+// ./json/status.json so overlapping runs can be refused.
 type Status struct {
 	Running bool `json:"running"`
+	// Pid, Trigger and StartedAt describe the run in progress.
+	Pid       int       `json:"pid,omitempty"`
+	Trigger   string    `json:"trigger,omitempty"`
+	StartedAt time.Time `json:"startedAt,omitempty"`
+
+	LastStart       time.Time `json:"lastStart,omitempty"`
+	LastEnd         time.Time `json:"lastEnd,omitempty"`
+	LastCompleted   bool      `json:"lastCompleted"`
+	LastAbortReason string    `json:"lastAbortReason,omitempty"`
 }
 
-func (p *Status) GetStatus() {
-	// check if json folder exists
+func (p *Status) GetStatus() error {
 	exists := os.IsExist(os.Mkdir("./json", 0755))
 	if exists {
-		fmt.Println("Json folder exists")
+		log.Println("Json folder exists")
 	}
-	// check if parsedFiles.json exists
-	_, err := os.Stat("./json/status.json")
-	if err != nil {
-		// create the file { "drives": [] }
-		file, err := os.Create("./json/status.json")
-		if err != nil {
-			fmt.Println("Error creating file")
-			panic(err)
-		}
-		defer func(file *os.File) {
-			err := file.Close()
-			if err != nil {
-				fmt.Println("Error closing file")
-				panic(err)
-			}
-		}(file)
-		emptyData := Status{Running: true}
-		jsonParser := json.NewEncoder(file)
-		err = jsonParser.Encode(emptyData)
-		if err != nil {
-			fmt.Println("Error saving json")
-			panic(err)
-		}
+	file, err := os.Open(statusPath)
+	if errors.Is(err, os.ErrNotExist) {
+		*p = Status{}
+		return nil
 	}
-
-	file, err := os.Open("./json/status.json")
 	if err != nil {
-		fmt.Println("Error opening file")
-		panic(err)
+		log.Println("Error opening status")
+		return err
 	}
 	defer func(file *os.File) {
 		err := file.Close()
 		if err != nil {
-			fmt.Println("Error closing file")
-			panic(err)
+			log.Println("Error closing file")
 		}
 	}(file)
 
-	jsonParser := json.NewDecoder(file)
-	err = jsonParser.Decode(p)
+	err = json.NewDecoder(file).Decode(p)
 	if err != nil {
-		fmt.Println("Error parsing json")
-		panic(err)
+		log.Println("Error decoding status")
+		return err
 	}
+	return nil
 }
-func (p *Status) Save() {
-	file, err := os.Create("./json/status.json")
+
+func (p *Status) Save() error {
+	file, err := os.Create(statusPath)
 	if err != nil {
-		fmt.Println("Error creating file")
-		panic(err)
+		log.Println("Error creating file")
+		return err
 	}
 	defer func(file *os.File) {
 		err := file.Close()
 		if err != nil {
-			fmt.Println("Error closing file")
-			panic(err)
+			log.Println("Error closing file")
 		}
 	}(file)
 	jsonParser := json.NewEncoder(file)
+	jsonParser.SetIndent("", "  ")
 	err = jsonParser.Encode(p)
 	if err != nil {
-		fmt.Println("Error saving json")
-		panic(err)
+		log.Println("Error saving json")
+		return err
 	}
+	return nil
+}
+
+// Start marks a run as started by this process.
+func (p *Status) Start(trigger string, start time.Time) {
+	p.Running = true
+	p.Pid = os.Getpid()
+	p.Trigger = trigger
+	p.StartedAt = start
+}
+
+// Finish records how the running sweep ended and clears it.
+func (p *Status) Finish(end time.Time, completed bool, abortReason string) {
+	p.LastStart = p.StartedAt
+	p.LastEnd = end
+	p.LastCompleted = completed
+	p.LastAbortReason = abortReason
+	p.Running = false
+	p.Pid = 0
+	p.Trigger = ""
+	p.StartedAt = time.Time{}
 }
@@ -0,0 +1,16 @@
+package models
+
+type CostSheetUploadRequest struct {
+	Url string `json:"url"`
+}
+
+// SheetStatusRequest marks a pricing sheet with the reason it needs attention
+// and who should act on it.
+type SheetStatusRequest struct {
+	SheetID    string `json:"sheetID"`
+	Reason     string `json:"reason"`
+	Resolution string `json:"resolution"`
+	Title      string `json:"title"`
+	OwnerName  string `json:"ownerName,omitempty"`
+	OwnerEmail string `json:"ownerEmail,omitempty"`
+}
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -30,20 +30,20 @@ func (this *SurpriceResponse) JSON(response *http.Response) error {
 	var ResponseJson SurpriceResponse
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		fmt.Println("Error reading response body")
+		log.Println("Error reading response body")
 		return err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			fmt.Println("Error closing response body")
+			log.Println("Error closing response body")
 		}
 	}(response.Body)
 	if err := json.Unmarshal(body, &ResponseJson); err != nil {
 		// print out the body for debugging
-		fmt.Println(string(body))
-		fmt.Println("Error unmarshalling response body")
-		fmt.Println(err)
+		log.Println(string(body))
+		log.Println("Error unmarshalling response body")
+		log.Println(err)
 		return err
 	}
 	*this = ResponseJson
@@ -80,6 +80,10 @@ type DriveParserResponse struct {
 	Error   bool            `json:"error"`
 	Message string          `json:"message"`
 	Data    DriveParserData `json:"data"`
+
+	// Code is the machine-readable form of Message. Use Result, which also
+	// covers servers that only send Message.
+	Code DriveParserResult `json:"code,omitempty"`
 }
 
 func (d DriveParserResponse) String() string {
@@ -98,7 +102,7 @@ type DriveStatusData struct {
 }
 
 func (d DriveStatusData) String() string {
-	return fmt.Sprintf("PO Creation Date: %s\nPO Creation Status: %s\nSheet ID: %s\nSheet Name: %s\nIs Reviewed: %t\nWho Reviewed: %s\nPO Created By: %s\n", d.PoCreationDate, d.PoCreationStatus, d.SheetId, d.SheetName, d.IsReviewed, d.WhoReviewed, d.PoCreatedBy)
+	return fmt.Sprintf("PO Creation Date: %s\nPO Creation Status: %t\nSheet ID: %s\nSheet Name: %s\nIs Reviewed: %t\nWho Reviewed: %s\nPO Created By: %s\n", d.PoCreationDate, d.PoCreationStatus, d.SheetId, d.SheetName, d.IsReviewed, d.WhoReviewed, d.PoCreatedBy)
 }
 
 type DriveStatusResponse struct {
@@ -111,45 +115,34 @@ func (d DriveStatusResponse) String() string {
 	return fmt.Sprintf("Error: %t\nMessage: %s\nData: %v", d.Error, d.Message, d.Data.String())
 }
 
-type Statistics struct {
-	Start                             time.Time `json:"start"`
-	End                               time.Time `json:"end"`
-	Completed                         bool      `json:"completed"`
-	TotalFiles                        int       `json:"totalFiles"`
-	SkippedFiles                      int       `json:"skippedFiles"`
-	ProcessedFiles                    int       `json:"processedFiles"`
-	CallsToDriveParser                int       `json:"callsToDriveParser"`
-	PosGenerated                      int       `json:"posGenerated"`
-	TotalExecutionTime                string    `json:"totalExecutionTime"`
-	TotalTimeSleeping                 string    `json:"totalTimeSleeping"`
-	TotalTimeWaitingForCost           string    `json:"totalTimeWaitingForCost"`
-	TotalTimeWaitingForDriveParserApi string    `json:"totalTimeWaitingForDriveParserApi"`
+// PhaseStats summarises the timings of one phase of a run.
+type PhaseStats struct {
+	Phase   string `json:"phase"`
+	Count   int    `json:"count"`
+	Errors  int    `json:"errors"`
+	TotalMs int64  `json:"totalMs"`
+	P50Ms   int64  `json:"p50Ms"`
+	P95Ms   int64  `json:"p95Ms"`
+	MaxMs   int64  `json:"maxMs"`
 }
 
-func (s *Statistics) SendStats(url string, client *http.Client) {
-	statsJson, err := json.Marshal(s)
-	if err != nil {
-		fmt.Println("Error marshalling stats")
-		fmt.Println(err)
-		return
-	}
-	resp, err := client.Post(url, "application/json", strings.NewReader(string(statsJson)))
-	if err != nil {
-		fmt.Println("Error sending stats")
-		fmt.Println(err)
-		return
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("Error closing response body")
-			fmt.Println(err)
-		}
-	}(resp.Body)
-
-	if resp.StatusCode != 200 {
-		fmt.Println("Error sending stats")
-		fmt.Println(resp.Status)
-	}
-	fmt.Println("Stats sent successfully")
+// Statistics is sent to Surprice at the end of every run, including one that
+// aborted, which has Completed false and the reason in AbortReason. Durations
+// are in milliseconds.
+type Statistics struct {
+	Start                 time.Time      `json:"start"`
+	End                   time.Time      `json:"end"`
+	Completed             bool           `json:"completed"`
+	AbortReason           string         `json:"abortReason,omitempty"`
+	TotalFiles            int            `json:"totalFiles"`
+	SkippedFiles          int            `json:"skippedFiles"`
+	ProcessedFiles        int            `json:"processedFiles"`
+	CallsToDriveParser    int            `json:"callsToDriveParser"`
+	PosGenerated          int            `json:"posGenerated"`
+	TotalExecutionMs      int64          `json:"totalExecutionMs"`
+	TotalSleepingMs       int64          `json:"totalSleepingMs"`
+	TotalWaitingForCostMs int64          `json:"totalWaitingForCostMs"`
+	TotalDriveParserApiMs int64          `json:"totalDriveParserApiMs"`
+	Phases                []PhaseStats   `json:"phases"`
+	ErrorsByCategory      map[string]int `json:"errorsByCategory"`
 }
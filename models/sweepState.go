@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+const sweepStatePath = "./json/sweepState.json"
+
+type KnownFolder struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SweepState carries what an incremental sweep needs from the previous run:
+// the Drive changes page token to continue from and the procurement folders
+// that were seen, so aged folders can be picked up without re-listing.
+// Folders whose last attempt errored are kept in Errored and retried by every
+// incremental run until they succeed.
+type SweepState struct {
+	PageToken string                 `json:"pageToken"`
+	LastRun   time.Time              `json:"lastRun"`
+	Folders   map[string]KnownFolder `json:"folders"`
+	Errored   map[string]bool        `json:"errored,omitempty"`
+}
+
+func (s *SweepState) GetSweepState() error {
+	exists := os.IsExist(os.Mkdir("./json", 0755))
+	if exists {
+		log.Println("Json folder exists")
+	}
+	s.Folders = map[string]KnownFolder{}
+	s.Errored = map[string]bool{}
+	file, err := os.Open(sweepStatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		log.Println("Error opening sweep state")
+		return err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Println("Error closing file")
+		}
+	}(file)
+
+	err = json.NewDecoder(file).Decode(s)
+	if err != nil {
+		log.Println("Error decoding sweep state")
+		return err
+	}
+	if s.Folders == nil {
+		s.Folders = map[string]KnownFolder{}
+	}
+	if s.Errored == nil {
+		s.Errored = map[string]bool{}
+	}
+	return nil
+}
+
+// RecordOutcome keeps folderId for a retry when outcome is OutcomeError and
+// forgets it otherwise.
+func (s *SweepState) RecordOutcome(folderId string, outcome string) {
+	if outcome == OutcomeError {
+		s.Errored[folderId] = true
+		return
+	}
+	delete(s.Errored, folderId)
+}
+
+// Forget drops a folder that has left the procurement folder.
+func (s *SweepState) Forget(folderId string) {
+	delete(s.Folders, folderId)
+	delete(s.Errored, folderId)
+}
+
+// CrossedAge returns the known folders that were younger than days at the last
+// run and have reached it since.
+func (s *SweepState) CrossedAge(days int, now time.Time) []string {
+	var crossed []string
+	threshold := time.Duration(days) * 24 * time.Hour
+	for id, folder := range s.Folders {
+		if folder.CreatedAt.IsZero() {
+			continue
+		}
+		if s.LastRun.Sub(folder.CreatedAt) < threshold && now.Sub(folder.CreatedAt) >= threshold {
+			crossed = append(crossed, id)
+		}
+	}
+	return crossed
+}
+
+func (s *SweepState) Save() error {
+	tmpPath := sweepStatePath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		log.Println("Error creating file")
+		return err
+	}
+	err = json.NewEncoder(file).Encode(s)
+	if err != nil {
+		log.Println("Error saving json")
+		_ = file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		log.Println("Error closing file")
+		return err
+	}
+	return os.Rename(tmpPath, sweepStatePath)
+}
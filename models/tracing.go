@@ -0,0 +1,30 @@
+package models
+
+import "fmt"
+
+// Trace exporters the sweep can send its spans to.
+const (
+	TracingExporterNone = ""
+	TracingExporterOtlp = "otlp"
+	TracingExporterFile = "file"
+)
+
+// TracingConfig chooses where the spans of a run go. Tracing is off unless an
+// exporter is set.
+type TracingConfig struct {
+	Exporter string `json:"exporter"`
+	// Endpoint is the OTLP/HTTP collector base URL, e.g. http://localhost:4318.
+	// OTEL_EXPORTER_OTLP_ENDPOINT is used when it is empty.
+	Endpoint string `json:"endpoint"`
+	// File is where the file exporter writes one JSON span per line.
+	File        string `json:"file"`
+	ServiceName string `json:"serviceName"`
+}
+
+func (t TracingConfig) validate() error {
+	switch t.Exporter {
+	case TracingExporterNone, TracingExporterOtlp, TracingExporterFile:
+		return nil
+	}
+	return fmt.Errorf("unknown tracing exporter %q", t.Exporter)
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Events that can update Insightly.
+const (
+	WriteBackPoCreated     = "poCreated"
+	WriteBackMovedToWins   = "movedToWins"
+	WriteBackMovedToLosses = "movedToLosses"
+)
+
+// WriteBackValues fill the {poNumber}, {sheetUrl} and {sheetName}
+// placeholders in note and field templates.
+type WriteBackValues struct {
+	PoNumber  string
+	SheetUrl  string
+	SheetName string
+}
+
+func (v WriteBackValues) Expand(template string) string {
+	return strings.NewReplacer(
+		"{poNumber}", v.PoNumber,
+		"{sheetUrl}", v.SheetUrl,
+		"{sheetName}", v.SheetName,
+	).Replace(template)
+}
+
+// WriteBackUpdate is what to change on the opportunity for one event. Empty
+// fields are left alone.
+type WriteBackUpdate struct {
+	NoteTitle string `json:"noteTitle,omitempty"`
+	NoteBody  string `json:"noteBody,omitempty"`
+	// CustomField is the FIELD_NAME set to CustomFieldValue.
+	CustomField      string `json:"customField,omitempty"`
+	CustomFieldValue string `json:"customFieldValue,omitempty"`
+	StageId          int    `json:"stageId,omitempty"`
+}
+
+func (u WriteBackUpdate) IsEmpty() bool {
+	return u.NoteBody == "" && u.CustomField == "" && u.StageId == 0
+}
+
+// WriteBackConfig controls updating Insightly after the sweep acts on a
+// folder. Nothing is written unless Enabled is set, and with DryRun the
+// updates are only logged.
+type WriteBackConfig struct {
+	Enabled bool                       `json:"enabled"`
+	DryRun  bool                       `json:"dryRun"`
+	Events  map[string]WriteBackUpdate `json:"events"`
+}
+
+func (c WriteBackConfig) validate() error {
+	for event := range c.Events {
+		switch event {
+		case WriteBackPoCreated, WriteBackMovedToWins, WriteBackMovedToLosses:
+		default:
+			return fmt.Errorf("unknown write back event %q", event)
+		}
+	}
+	return nil
+}
+
+// Update returns what to write for event, false when there is nothing to do.
+func (c WriteBackConfig) Update(event string) (WriteBackUpdate, bool) {
+	if !c.Enabled {
+		return WriteBackUpdate{}, false
+	}
+	update, ok := c.Events[event]
+	return update, ok && !update.IsEmpty()
+}
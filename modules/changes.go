@@ -0,0 +1,80 @@
+package modules
+
+import (
+	"github.com/mwalkersigma/drive-parser/models"
+	drive "google.golang.org/api/drive/v3"
+	"log"
+	"slices"
+)
+
+const folderMimeType = "application/vnd.google-apps.folder"
+
+func GetStartPageToken() (string, error) {
+	token, err := driveService.Changes.GetStartPageToken().Do()
+	if err != nil {
+		log.Println("Error getting start page token")
+		return "", err
+	}
+	return token.StartPageToken, nil
+}
+
+// ChangedFolders walks the Drive changes feed from pageToken and returns the
+// folders directly under parentFolderId that were created, or whose files were
+// touched, since then. Known folders that have left parentFolderId are dropped
+// from state. The returned token is where the next run should continue from.
+func ChangedFolders(pageToken string, parentFolderId string, state *models.SweepState) ([]*drive.File, string, error) {
+	changed := map[string]*drive.File{}
+	for {
+		changeList, err := driveService.Changes.List(pageToken).
+			Fields("nextPageToken, newStartPageToken, changes(fileId, removed, file(id, name, mimeType, parents, trashed))").
+			PageSize(1000).
+			Do()
+		if err != nil {
+			log.Println("Error listing changes")
+			return nil, "", err
+		}
+		for _, change := range changeList.Changes {
+			if change.Removed || change.File == nil {
+				state.Forget(change.FileId)
+				delete(changed, change.FileId)
+				continue
+			}
+			file := change.File
+			if file.MimeType == folderMimeType {
+				if slices.Contains(file.Parents, parentFolderId) && !file.Trashed {
+					changed[file.Id] = &drive.File{Id: file.Id, Name: file.Name}
+				} else {
+					state.Forget(file.Id)
+					delete(changed, file.Id)
+				}
+				continue
+			}
+			for _, parent := range file.Parents {
+				if known, ok := state.Folders[parent]; ok {
+					changed[parent] = &drive.File{Id: parent, Name: known.Name}
+				}
+			}
+		}
+		if changeList.NewStartPageToken != "" {
+			pageToken = changeList.NewStartPageToken
+			break
+		}
+		pageToken = changeList.NextPageToken
+	}
+
+	var folders []*drive.File
+	for _, folder := range changed {
+		folders = append(folders, folder)
+	}
+	return folders, pageToken, nil
+}
+
+// IsInFolder reports whether fileId is still a live child of parentFolderId.
+func IsInFolder(fileId string, parentFolderId string) (bool, error) {
+	file, err := driveService.Files.Get(fileId).Fields("id, parents, trashed").Do()
+	if err != nil {
+		log.Println("Error getting file")
+		return false, err
+	}
+	return !file.Trashed && slices.Contains(file.Parents, parentFolderId), nil
+}
@@ -4,21 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/joho/godotenv"
 	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/surprice"
+	"github.com/mwalkersigma/drive-parser/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	drive "google.golang.org/api/drive/v3"
-	"google.golang.org/api/option"
-	"io"
+	"log"
 	"math"
-	"net/http"
-	"os"
 	"strings"
 	"sync"
 	"time"
 )
 
 var driveService *drive.Service
-var surpriceURLSuspendSheet string
+var surpriceClient *surprice.Client
 
 func DaysOld(startDate time.Time, endDate time.Time) int {
 	// Calculate the numbers of days rounded down to the nearest date
@@ -28,19 +27,11 @@ func DaysOld(startDate time.Time, endDate time.Time) int {
 	return int(days)
 }
 
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		panic(err)
-	}
-	ctx := context.Background()
-	ds, dsErr := drive.NewService(ctx, option.WithCredentialsFile("./cert.json"))
-	if dsErr != nil {
-		fmt.Println("Error creating new service")
-		panic(dsErr)
-	}
+// Setup gives the package the Drive service the workers list folders with and
+// the client sheets are marked through. Call it before using either.
+func Setup(ds *drive.Service, client *surprice.Client) {
 	driveService = ds
-	surpriceURLSuspendSheet = fmt.Sprintf("%s/api/v1/costSheet/status", os.Getenv("BASE_URL"))
+	surpriceClient = client
 }
 
 func PrettyPrint(i interface{}) string {
@@ -63,125 +54,140 @@ type WorkerResult struct {
 	// need a date field for creation
 	CreatedAt time.Time
 	Age       int
+	// Log holds what the worker printed about this folder so it can be shown
+	// alongside the rest of the folder's output.
+	Log string
+	// EnumerationTime is how long listing the folder took.
+	EnumerationTime time.Duration
+	// Context carries the folder's trace span, started when the folder was
+	// listed. Whoever finishes with the folder ends the span.
+	Context context.Context
+	// Err is why the folder could not be listed. The other fields are only
+	// partly filled in when it is set.
+	Err error
 }
 
 func Worker(jobs <-chan string, results chan<- WorkerResult) {
-	fmt.Println("Worker started")
+	log.Println("Worker started")
 	for j := range jobs {
+		listStart := time.Now()
+		ctx, _ := tracing.Start(context.Background(), "folder", attribute.String("folder.id", j))
 		innerFiles, err := driveService.
 			Files.
 			List().
 			Fields("files(id, name, createdTime)").
 			Q(fmt.Sprintf("'%s' in parents and mimeType != 'application/vnd.google-apps.folder' ", j)).
+			Context(ctx).
 			Do()
 		if err != nil {
-			fmt.Println("Error getting files from folder")
-			panic(err)
+			log.Println("Error getting files from folder")
+			results <- WorkerResult{ParentFolderId: j, EnumerationTime: time.Since(listStart), Context: ctx, Err: err}
+			continue
 		}
+		var folderLog strings.Builder
 		var fileIds []FileDetails
 		var CreatedTime time.Time
 		var age int
 		endDate := time.Now()
 		for _, file := range innerFiles.Files {
 
-			fmt.Println("File Create Date: ", file.CreatedTime)
+			fmt.Fprintln(&folderLog, "File Create Date: ", file.CreatedTime)
 			CreatedTime, err = time.Parse(time.RFC3339, file.CreatedTime)
 			if err != nil {
-				fmt.Println("Error parsing time")
-				panic(err)
+				fmt.Fprintln(&folderLog, "Error parsing time")
+				err = fmt.Errorf("parsing the created time of %s: %w", file.Name, err)
+				break
 			}
 			age = DaysOld(CreatedTime, endDate)
-			fmt.Println("File: ", file.Name, " ID: ", file.Id, "Created: ", CreatedTime, "Age: ", age)
+			fmt.Fprintln(&folderLog, "File: ", file.Name, " ID: ", file.Id, "Created: ", CreatedTime, "Age: ", age)
 			fileDetails := FileDetails{Name: file.Name, Id: file.Id}
 			fileIds = append(fileIds, fileDetails)
 		}
 
-		results <- WorkerResult{FileDetails: fileIds, FileIdsCount: len(innerFiles.Files), ParentFolderId: j, CreatedAt: CreatedTime, Age: age}
+		results <- WorkerResult{FileDetails: fileIds, FileIdsCount: len(innerFiles.Files), ParentFolderId: j, CreatedAt: CreatedTime, Age: age, Log: folderLog.String(), EnumerationTime: time.Since(listStart), Context: ctx, Err: err}
 	}
-	fmt.Println("Worker finished")
+	log.Println("Worker finished")
 }
 
-func SetupWorkers(workerCount int, jobCount int) (chan string, chan WorkerResult, *sync.WaitGroup) {
-	jobs := make(chan string, jobCount)
-	results := make(chan WorkerResult, jobCount)
+// OnWorkerPanic, when set, is called with what a worker panicked with before
+// the panic carries on.
+var OnWorkerPanic func(recovered interface{})
+
+// SetupWorkers starts workerCount workers listing the files of each folder ID
+// sent on jobs. Both channels hold at most bufferSize entries, so whoever feeds
+// jobs should do it from its own goroutine while results are being read.
+// results is closed once jobs is closed and every worker has finished.
+func SetupWorkers(workerCount int, bufferSize int) (chan string, <-chan WorkerResult) {
+	jobs := make(chan string, bufferSize)
+	results := make(chan WorkerResult, bufferSize)
 	wg := sync.WaitGroup{}
 	for w := 1; w <= workerCount; w++ {
 		wg.Add(1)
 		go func(workerId int) {
 			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					if OnWorkerPanic != nil {
+						OnWorkerPanic(r)
+					}
+					panic(r)
+				}
+			}()
 			Worker(jobs, results)
 		}(w)
 	}
-	return jobs, results, &wg
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return jobs, results
 }
 
-func MarkSheet(reason string, resolution string) func(string, string) (bool, error) {
-	return func(sheetID string, title string) (bool, error) {
-		var client = &http.Client{Timeout: 60 * time.Second * 5}
+// MarkSheet returns a function marking a sheet with reason. When the
+// opportunity owner is known they are named in the resolution and sent along
+// so the notice can reach them.
+func MarkSheet(reason string, resolution string) func(context.Context, *log.Logger, string, string, models.OpportunityOwner) (bool, error) {
+	return func(ctx context.Context, logger *log.Logger, sheetID string, title string, owner models.OpportunityOwner) (bool, error) {
 		expectedSuccessResponse := "Sheet has been marked with failure reason"
-		body := fmt.Sprintf(`{"sheetID": "%s", "reason": "%s", "resolution": "%s", "title": "%s"}`, sheetID, reason, resolution, title)
-		resp, err := client.Post(surpriceURLSuspendSheet,
-			"application/json",
-			strings.NewReader(body))
-		if err != nil {
-			fmt.Println("Error calling Drive Parser to suspend sheet")
-			fmt.Println(err)
-			return false, err
+		ownerResolution := resolution
+		if owner.String() != "" {
+			ownerResolution = strings.Replace(resolution, "the Opportunity Owner", "the Opportunity Owner, "+owner.String()+",", 1)
 		}
-
-		defer func(Body io.ReadCloser) {
-			err := Body.Close()
-			if err != nil {
-				fmt.Println("Error closing response body")
-				fmt.Println(err)
-			}
-		}(resp.Body)
-
-		var target models.DriveStatusResponse
-		err = json.NewDecoder(resp.Body).Decode(&target)
+		target, err := surpriceClient.MarkSheet(ctx, models.SheetStatusRequest{
+			SheetID:    sheetID,
+			Reason:     reason,
+			Resolution: ownerResolution,
+			Title:      title,
+			OwnerName:  owner.Name,
+			OwnerEmail: owner.Email,
+		})
 		if err != nil {
-			fmt.Println("Error decoding response")
-			fmt.Println(err)
+			logger.Println("Error calling Drive Parser to suspend sheet")
+			logger.Println(err)
 			return false, err
 		}
-		fmt.Println("Response: ", target.Message)
+		logger.Println("Response: ", target.Message)
 		correctResponse := target.Message == expectedSuccessResponse
 		return correctResponse, nil
 	}
 }
-func MarkSheetSuspended(sheetID string, title string) (bool, error) {
+func MarkSheetSuspended(ctx context.Context, logger *log.Logger, sheetID string, title string, owner models.OpportunityOwner) (bool, error) {
 	reason := "Sheet has not had cost put in for 60 or more days and is suspended in Insightly"
 	resolution := "Please communicate with the Opportunity Owner to determine if the opportunity is still active. If the opportunity is still active, please update the sheet with the correct cost."
-	return MarkSheet(reason, resolution)(sheetID, title)
+	return MarkSheet(reason, resolution)(ctx, logger, sheetID, title, owner)
 }
-func MarkSheetForgotten(sheetID string, title string) (bool, error) {
+func MarkSheetForgotten(ctx context.Context, logger *log.Logger, sheetID string, title string, owner models.OpportunityOwner) (bool, error) {
 	reason := "Sheet is currently in OPEN status and has not been updated in 60 or more days"
 	resolution := "Please communicate with the Opportunity Owner to determine if the opportunity is still active. If the opportunity is still active, please update the sheet with the correct cost."
-	return MarkSheet(reason, resolution)(sheetID, title)
+	return MarkSheet(reason, resolution)(ctx, logger, sheetID, title, owner)
 }
 
-func IsMarked(failureReason string) func(string) (bool, error) {
-	return func(SheetID string) (bool, error) {
-		var client = &http.Client{Timeout: 60 * time.Second * 5}
-		resp, err := client.Get(surpriceURLSuspendSheet + fmt.Sprintf("/%s", SheetID))
-		if err != nil {
-			fmt.Println("Error calling Drive Parser")
-			fmt.Println(err)
-			return false, err
-		}
-		defer func(Body io.ReadCloser) {
-			err := Body.Close()
-			if err != nil {
-				fmt.Println("Error closing response body")
-				fmt.Println(err)
-			}
-		}(resp.Body)
-
-		var target models.DriveStatusResponse
-		err = json.NewDecoder(resp.Body).Decode(&target)
+func IsMarked(failureReason string) func(context.Context, *log.Logger, string) (bool, error) {
+	return func(ctx context.Context, logger *log.Logger, SheetID string) (bool, error) {
+		target, err := surpriceClient.GetSheetStatus(ctx, SheetID)
 		if err != nil {
-			fmt.Println("Error decoding response")
-			fmt.Println(err)
+			logger.Println("Error calling Drive Parser")
+			logger.Println(err)
 			return false, err
 		}
 		receivedReason := target.Data.SheetFailureReason
@@ -189,20 +195,20 @@ func IsMarked(failureReason string) func(string) (bool, error) {
 			return true, nil
 		}
 		if failureReason == receivedReason && target.Data.IsReviewed {
-			fmt.Println("Sheet has been reviewed. Retrying")
+			logger.Println("Sheet has been reviewed. Retrying")
 			return false, nil
 		}
 		if target.Data.SheetFailureReason != "" {
-			fmt.Println("Failure Reason did not match expected")
-			fmt.Println("Expected Reason: ", failureReason)
-			fmt.Println("Received Reason: ", receivedReason)
+			logger.Println("Failure Reason did not match expected")
+			logger.Println("Expected Reason: ", failureReason)
+			logger.Println("Received Reason: ", receivedReason)
 		}
 		return false, nil
 	}
 }
-func IsMarkedSuspended(SheetID string) (bool, error) {
-	return IsMarked("Sheet has not had cost put in for 60 or more days and is suspended in Insightly")(SheetID)
+func IsMarkedSuspended(ctx context.Context, logger *log.Logger, SheetID string) (bool, error) {
+	return IsMarked("Sheet has not had cost put in for 60 or more days and is suspended in Insightly")(ctx, logger, SheetID)
 }
-func IsMarkedForgotten(SheetID string) (bool, error) {
-	return IsMarked("Sheet is currently in OPEN status and has not been updated in 60 or more days")(SheetID)
+func IsMarkedForgotten(ctx context.Context, logger *log.Logger, SheetID string) (bool, error) {
+	return IsMarked("Sheet is currently in OPEN status and has not been updated in 60 or more days")(ctx, logger, SheetID)
 }
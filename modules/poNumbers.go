@@ -0,0 +1,80 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	drive "google.golang.org/api/drive/v3"
+	"log"
+	"strings"
+)
+
+// Drive app properties the sweep keeps on procurement folders and their cost
+// sheets once a PO exists for them.
+const (
+	PoNumberProperty         = "poNumber"
+	PoResponseStatusProperty = "poResponseStatus"
+)
+
+// RecordPoNumber stores the PO number on the file as app properties, which
+// only this app can see and search by.
+func RecordPoNumber(ctx context.Context, fileId string, poNumber string, poResponseStatus string) error {
+	properties := map[string]string{PoNumberProperty: poNumber}
+	if poResponseStatus != "" {
+		properties[PoResponseStatusProperty] = poResponseStatus
+	}
+	_, err := driveService.Files.Update(fileId, &drive.File{AppProperties: properties}).Fields("id").Context(ctx).Do()
+	if err != nil {
+		log.Println("Error recording PO number")
+		return err
+	}
+	return nil
+}
+
+// GetFileProperties returns the file with its app properties. Its PO number
+// is file.AppProperties[PoNumberProperty], missing when none was recorded.
+func GetFileProperties(fileId string) (*drive.File, error) {
+	file, err := driveService.Files.Get(fileId).Fields("id, name, mimeType, parents, appProperties").Do()
+	if err != nil {
+		log.Println("Error getting file")
+		return nil, err
+	}
+	return file, nil
+}
+
+// FilesWithPo returns the folders and cost sheets the PO number was recorded
+// on.
+func FilesWithPo(poNumber string) ([]*drive.File, error) {
+	query := fmt.Sprintf("appProperties has { key='%s' and value='%s' } and trashed = false",
+		PoNumberProperty, escapeQueryValue(poNumber))
+	var files []*drive.File
+	pageToken := ""
+	for {
+		fileList, err := driveService.Files.List().
+			Fields("nextPageToken, files(id, name, mimeType, parents, appProperties)").
+			Q(query).
+			PageToken(pageToken).
+			Do()
+		if err != nil {
+			log.Println("Error searching for PO number")
+			return nil, err
+		}
+		files = append(files, fileList.Files...)
+		if fileList.NextPageToken == "" {
+			return files, nil
+		}
+		pageToken = fileList.NextPageToken
+	}
+}
+
+// queryValueEscaper escapes a value for a quoted string in a Drive query,
+// where backslashes and single quotes must be preceded by a backslash.
+var queryValueEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func escapeQueryValue(value string) string {
+	return queryValueEscaper.Replace(value)
+}
+
+// IsFolder reports whether the file is a Drive folder.
+func IsFolder(file *drive.File) bool {
+	return file.MimeType == folderMimeType
+}
@@ -0,0 +1,17 @@
+package modules
+
+import "testing"
+
+func TestEscapeQueryValue(t *testing.T) {
+	tests := map[string]string{
+		`PO-10042`:  `PO-10042`,
+		`O'Brien`:   `O\'Brien`,
+		`PO\10042`:  `PO\\10042`,
+		`PO\'10042`: `PO\\\'10042`,
+	}
+	for value, want := range tests {
+		if got := escapeQueryValue(value); got != want {
+			t.Errorf("escapeQueryValue(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
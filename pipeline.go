@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/modules"
+	"github.com/mwalkersigma/drive-parser/stats"
+	"github.com/mwalkersigma/drive-parser/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+type folderJob struct {
+	seq    int
+	result modules.WorkerResult
+}
+
+// folderReport is the outcome of processing one folder along with everything
+// it logged, so the output can be printed as one uninterrupted block.
+type folderReport struct {
+	seq          int
+	result       modules.WorkerResult
+	outcome      string
+	needsTimeout bool
+	err          error
+	entry        models.FolderReport
+	log          bytes.Buffer
+}
+
+// processStage decides and acts on folders from results with workerCount
+// goroutines. Reports come back in the order the folders were received.
+//
+// At most twice workerCount folders are dispatched ahead of the oldest one
+// not yet reported, so a slow folder holds the others back instead of
+// letting their reports pile up.
+func processStage(results <-chan modules.WorkerResult, workerCount int) <-chan *folderReport {
+	jobs := make(chan folderJob)
+	done := make(chan *folderReport, workerCount)
+	ordered := make(chan *folderReport, workerCount)
+	window := make(chan struct{}, 2*workerCount)
+
+	go func() {
+		seq := 0
+		for result := range results {
+			window <- struct{}{}
+			jobs <- folderJob{seq: seq, result: result}
+			seq++
+		}
+		close(jobs)
+	}()
+
+	wg := sync.WaitGroup{}
+	for w := 1; w <= workerCount; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer abortOnPanic()
+			for job := range jobs {
+				recorder.Observe(stats.PhaseEnumerate, job.result.EnumerationTime, job.result.Err)
+				report := &folderReport{seq: job.seq, result: job.result}
+				report.entry.FolderId = job.result.ParentFolderId
+				logger := log.New(&report.log, "", 0)
+				logger.Println()
+				report.log.WriteString(job.result.Log)
+				ctx := job.result.Context
+				span := trace.SpanFromContext(ctx)
+				report.outcome, report.needsTimeout, report.err = processResult(ctx, logger, job.result, &report.entry)
+				span.SetAttributes(
+					attribute.String("folder.outcome", report.outcome),
+					attribute.String("folder.sheet", report.entry.SheetName),
+				)
+				if report.entry.OpportunityId != "" {
+					span.SetAttributes(attribute.String("insightly.opportunity_id", report.entry.OpportunityId))
+				}
+				if report.entry.PoNumber != "" {
+					span.SetAttributes(attribute.String("surprice.po_number", report.entry.PoNumber))
+				}
+				tracing.End(span, report.err)
+				if report.needsTimeout {
+					logger.Printf("Sleeping for %d seconds\n", timeout)
+				}
+				done <- report
+				// The pause only holds up this worker, the others keep going.
+				if report.needsTimeout {
+					sleepStart := time.Now()
+					time.Sleep(time.Duration(timeout) * time.Second)
+					recorder.Since(stats.PhaseSleep, sleepStart, nil)
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	go func() {
+		pending := map[int]*folderReport{}
+		next := 0
+		for report := range done {
+			pending[report.seq] = report
+			for {
+				nextReport, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				ordered <- nextReport
+				<-window
+			}
+		}
+		close(ordered)
+	}()
+	return ordered
+}
+
+// print writes what the folder logged to stdout, or with -log-format json one
+// object holding its report entry and log lines to jsonOut.
+func (r *folderReport) print() {
+	if jsonOut != nil {
+		lines := strings.Split(strings.TrimRight(r.log.String(), "\n"), "\n")
+		err := json.NewEncoder(jsonOut).Encode(struct {
+			Type string `json:"type"`
+			models.FolderReport
+			Log []string `json:"log"`
+		}{"folder", r.entry, lines})
+		if err != nil {
+			log.Println("Error writing folder log")
+			log.Println(err)
+		}
+		return
+	}
+	_, err := r.log.WriteTo(log.Writer())
+	if err != nil {
+		log.Println("Error writing folder log")
+		log.Println(err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/modules"
+	"log"
+	"strings"
+)
+
+// poLookupCommand answers which PO a folder or cost sheet produced, and which
+// folder a PO came from, using the PO numbers the sweep records on them.
+//
+//	po-lookup po-of <folder or sheet ID or URL>
+//	po-lookup folder-of <PO number>
+func poLookupCommand(args []string) int {
+	flags := commandFlags("po-lookup")
+	err := flags.Parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if flags.NArg() != 2 {
+		return poLookupUsage()
+	}
+	var lookup func(string) int
+	switch flags.Arg(0) {
+	case "po-of":
+		lookup = poOf
+	case "folder-of":
+		lookup = folderOf
+	default:
+		return poLookupUsage()
+	}
+	err = loadConfig()
+	if err == nil {
+		err = setupGoogle()
+	}
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	return lookup(flags.Arg(1))
+}
+
+func poLookupUsage() int {
+	log.Println("Usage:")
+	log.Println("  drive-parser po-lookup po-of <folder or sheet ID or URL>")
+	log.Println("  drive-parser po-lookup folder-of <PO number>")
+	return exitUsage
+}
+
+// fileIdFrom accepts a bare ID or a Drive folder or Sheets URL.
+func fileIdFrom(arg string) string {
+	for _, marker := range []string{"/folders/", "/d/"} {
+		if _, rest, found := strings.Cut(arg, marker); found {
+			id, _, _ := strings.Cut(rest, "/")
+			id, _, _ = strings.Cut(id, "?")
+			return id
+		}
+	}
+	return strings.TrimSpace(arg)
+}
+
+func poOf(arg string) int {
+	file, err := modules.GetFileProperties(fileIdFrom(arg))
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	poNumber, ok := file.AppProperties[modules.PoNumberProperty]
+	if !ok {
+		log.Printf("No PO recorded for %s\n", file.Name)
+		return exitFailed
+	}
+	line := fmt.Sprintf("%s: %s", file.Name, poNumber)
+	if status := file.AppProperties[modules.PoResponseStatusProperty]; status != "" {
+		line += fmt.Sprintf(" (%s)", status)
+	}
+	log.Println(line)
+	return exitOK
+}
+
+func folderOf(poNumber string) int {
+	files, err := modules.FilesWithPo(poNumber)
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	// The folder normally carries the PO itself; a cost sheet on its own
+	// still points at the folder it sits in.
+	folders := map[string]string{}
+	for _, file := range files {
+		if modules.IsFolder(file) {
+			folders[file.Id] = file.Name
+		}
+	}
+	for _, file := range files {
+		if modules.IsFolder(file) {
+			continue
+		}
+		for _, parent := range file.Parents {
+			if _, ok := folders[parent]; !ok {
+				folders[parent] = parentName(parent)
+			}
+		}
+	}
+	if len(folders) == 0 {
+		log.Printf("No folder recorded for PO %s\n", poNumber)
+		return exitFailed
+	}
+	for id, name := range folders {
+		log.Printf("%s: https://drive.google.com/drive/folders/%s\n", name, id)
+	}
+	return exitOK
+}
+
+func parentName(folderId string) string {
+	folder, err := modules.GetFileProperties(folderId)
+	if err != nil {
+		return folderId
+	}
+	return folder.Name
+}
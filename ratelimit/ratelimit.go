@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limiter spaces calls evenly so no more than the configured number happen
+// per interval. A nil Limiter, or one with a zero rate, never waits. Limiters
+// are shared between goroutines so every caller of an API draws from the same
+// budget.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func NewPerMinute(calls int) *Limiter {
+	l := &Limiter{}
+	l.SetRate(calls, time.Minute)
+	return l
+}
+
+func NewPerSecond(calls int) *Limiter {
+	l := &Limiter{}
+	l.SetRate(calls, time.Second)
+	return l
+}
+
+// SetRate changes the limit to calls per period. Zero or less removes it.
+func (l *Limiter) SetRate(calls int, period time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if calls <= 0 {
+		l.interval = 0
+		return
+	}
+	l.interval = period / time.Duration(calls)
+}
+
+// Wait blocks until the caller may make its call or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if l.interval == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Transport is an http.RoundTripper that waits on Limiter before every
+// request it sends through Base.
+type Transport struct {
+	Base    http.RoundTripper
+	Limiter *Limiter
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	err := t.Limiter.Wait(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
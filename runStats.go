@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/runlock"
+	"github.com/mwalkersigma/drive-parser/stats"
+	"github.com/mwalkersigma/drive-parser/tracing"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Folder counts for the run statistics.
+var totalFiles atomic.Int64
+var resumedFiles atomic.Int64
+var processedFiles atomic.Int64
+var sleeplessFiles atomic.Int64
+
+var statsSent sync.Once
+
+// What started a run, as recorded in the status file.
+const (
+	triggerManual   = "manual"
+	triggerSchedule = "schedule"
+	triggerHttp     = "http"
+)
+
+// runStatus mirrors ./json/status.json for the run this process is doing.
+var runStatus models.Status
+var runStatusMu sync.Mutex
+
+// runLock is held for as long as a run is in progress.
+var runLock *runlock.Lock
+
+// beginRun takes the run lock and claims the status file for a new run, then
+// resets the statistics. It fails when another command holds the lock.
+func beginRun(trigger string) error {
+	runStatusMu.Lock()
+	defer runStatusMu.Unlock()
+	lock, err := runlock.Acquire(runlock.DefaultPath, "sweep ("+trigger+")")
+	if err != nil {
+		return err
+	}
+	if lock.Stale != nil {
+		log.Println("Taking over the run lock left by ", *lock.Stale)
+	}
+	err = runStatus.GetStatus()
+	if err != nil {
+		lock.Release()
+		return err
+	}
+	if runStatus.Running {
+		// The lock was free, so the run the status file describes is gone.
+		log.Printf("The run started %s by pid %d did not finish, recording it as aborted\n",
+			runStatus.StartedAt.Format(time.RFC3339), runStatus.Pid)
+		runStatus.Finish(time.Now(), false, "process exited before the run finished")
+	}
+	resetRunStatistics(time.Now())
+	runStatus.Start(trigger, start)
+	err = runStatus.Save()
+	if err != nil {
+		lock.Release()
+		return err
+	}
+	runLock = lock
+	return nil
+}
+
+// finishRun sends the statistics of the run in progress, marks it as over in
+// the status file and releases the run lock. It does nothing when no run is
+// in progress, so every way a run can end may call it.
+func finishRun(completed bool, abortReason string) {
+	runStatusMu.Lock()
+	defer runStatusMu.Unlock()
+	if !runStatus.Running {
+		return
+	}
+	sendStatistics(completed, abortReason)
+	runStatus.Finish(time.Now(), completed, abortReason)
+	err := runStatus.Save()
+	if err != nil {
+		log.Println("Error saving status")
+		log.Println(err)
+	}
+	err = runLock.Release()
+	if err != nil {
+		log.Println("Error releasing run lock")
+		log.Println(err)
+	}
+	runLock = nil
+}
+
+func resetRunStatistics(runStart time.Time) {
+	start = runStart
+	totalFiles.Store(0)
+	resumedFiles.Store(0)
+	processedFiles.Store(0)
+	sleeplessFiles.Store(0)
+	callsToDriveParser.Store(0)
+	posGenerated.Store(0)
+	recorder.Reset()
+	statsSent = sync.Once{}
+}
+
+func buildStatistics(end time.Time, completed bool, abortReason string) models.Statistics {
+	return models.Statistics{
+		Start:                 start,
+		End:                   end,
+		Completed:             completed,
+		AbortReason:           abortReason,
+		TotalFiles:            int(totalFiles.Load()),
+		SkippedFiles:          int(sleeplessFiles.Load()),
+		ProcessedFiles:        int(processedFiles.Load()),
+		CallsToDriveParser:    int(callsToDriveParser.Load()),
+		PosGenerated:          int(posGenerated.Load()),
+		TotalExecutionMs:      end.Sub(start).Milliseconds(),
+		TotalSleepingMs:       (recorder.Total(stats.PhaseSleep) + recorder.Total(stats.PhaseRetryWait)).Milliseconds(),
+		TotalWaitingForCostMs: recorder.Total(stats.PhaseCostRead).Milliseconds(),
+		TotalDriveParserApiMs: recorder.Total(stats.PhaseDriveParser).Milliseconds(),
+		Phases:                recorder.Phases(),
+		ErrorsByCategory:      recorder.Errors(),
+	}
+}
+
+func printStatistics(s models.Statistics) {
+	if jsonOut != nil {
+		err := json.NewEncoder(jsonOut).Encode(struct {
+			Type string `json:"type"`
+			models.Statistics
+		}{"statistics", s})
+		if err != nil {
+			log.Println("Error writing statistics")
+			log.Println(err)
+		}
+		return
+	}
+	elapsed := time.Duration(s.TotalExecutionMs) * time.Millisecond
+	percent := func(ms int64) float64 {
+		if s.TotalExecutionMs == 0 {
+			return 0
+		}
+		return float64(ms) / float64(s.TotalExecutionMs) * 100
+	}
+	log.Println(fmt.Sprintf("Processed %d Files", s.ProcessedFiles))
+	if resumedFiles.Load() > 0 {
+		log.Println(fmt.Sprintf("Skipped %d Files handled by the resumed run", resumedFiles.Load()))
+	}
+	log.Println(fmt.Sprintf("Total Execution time: %s", elapsed))
+	log.Println(fmt.Sprintf("Total POs Generated: %d", s.PosGenerated))
+	log.Println(fmt.Sprintf("Total time sleeping: %s || %.2f%% Percentage of total execution time ", time.Duration(s.TotalSleepingMs)*time.Millisecond, percent(s.TotalSleepingMs)))
+	log.Println(fmt.Sprintf("Total time waiting for cost: %s || %.2f%% Percentage of total execution time", time.Duration(s.TotalWaitingForCostMs)*time.Millisecond, percent(s.TotalWaitingForCostMs)))
+	log.Println(fmt.Sprintf("Total Calls to Drive Parser API: %d", s.CallsToDriveParser))
+	log.Println(fmt.Sprintf("Total time waiting for Drive Parser API: %s || %.2f%% Percentage of total execution time", time.Duration(s.TotalDriveParserApiMs)*time.Millisecond, percent(s.TotalDriveParserApiMs)))
+	log.Println()
+	log.Printf("%-14s %7s %7s %12s %10s %10s %10s\n", "Phase", "Count", "Errors", "Total", "p50", "p95", "Max")
+	for _, phase := range s.Phases {
+		log.Printf("%-14s %7d %7d %12s %10s %10s %10s\n", phase.Phase, phase.Count, phase.Errors,
+			time.Duration(phase.TotalMs)*time.Millisecond,
+			time.Duration(phase.P50Ms)*time.Millisecond,
+			time.Duration(phase.P95Ms)*time.Millisecond,
+			time.Duration(phase.MaxMs)*time.Millisecond)
+	}
+	for category, count := range s.ErrorsByCategory {
+		log.Printf("Errors (%s): %d\n", category, count)
+	}
+}
+
+// sendStatistics prints and sends the run statistics. Only the first call
+// does anything, so a run that aborts while finishing up is not reported
+// twice.
+func sendStatistics(completed bool, abortReason string) {
+	statsSent.Do(func() {
+		s := buildStatistics(time.Now(), completed, abortReason)
+		printStatistics(s)
+		if dryRun {
+			log.Println("Dry run: not sending statistics to Surtrics")
+			return
+		}
+		log.Println("Sending Statistics to Surtrics")
+		log.Println("Sending Stats to: ", surpriceClient.BaseURL)
+		err := surpriceClient.SendStats(context.Background(), &s)
+		if err != nil {
+			log.Println("Error sending stats")
+			log.Println(err)
+			return
+		}
+		log.Println("Stats sent successfully")
+	})
+}
+
+// abortOnPanic reports the run as aborted before letting the panic carry on.
+// Defer it at the top of every goroutine of the run that can panic.
+func abortOnPanic() {
+	if r := recover(); r != nil {
+		finishRun(false, fmt.Sprint(r))
+		shutdownTracing()
+		panic(r)
+	}
+}
+
+// handleSignals reports the run as aborted when it is interrupted. Finished
+// folders are already in the checkpoint, so --resume carries on from there.
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		received := <-signals
+		log.Println("Received ", received, ", stopping")
+		finishRun(false, "interrupted by "+received.String())
+		shutdownTracing()
+		os.Exit(1)
+	}()
+}
+
+// shutdownTracing sends the spans still waiting to be exported. Spans of
+// folders that were cut short by an abort are never ended, so they are lost.
+func shutdownTracing() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := tracing.Shutdown(ctx)
+	if err != nil {
+		log.Println("Error exporting traces")
+		log.Println(err)
+	}
+}
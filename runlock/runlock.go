@@ -0,0 +1,105 @@
+// Package runlock keeps two commands that change Drive, Insightly or SkuVault
+// from running at the same time.
+//
+// The lock is a file holding the PID, command and start time of the holder.
+// On unix it is held with flock, so the kernel lets go of it when the holder
+// exits, however it exits. Elsewhere the file is linked into place with the
+// holder already written, and a lock whose holder is no longer running is
+// taken over as stale.
+package runlock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultPath is the lock shared by every command of this repo.
+const DefaultPath = "./json/run.lock"
+
+// Holder is what the lock file says about who holds it.
+type Holder struct {
+	Pid       int       `json:"pid"`
+	Command   string    `json:"command"`
+	Host      string    `json:"host,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("%s (pid %d on %s) since %s", h.Command, h.Pid, h.Host, h.StartedAt.Format(time.RFC3339))
+}
+
+// LockedError is returned by Acquire when another command holds the lock.
+type LockedError struct {
+	Path   string
+	Holder Holder
+}
+
+func (e *LockedError) Error() string {
+	if e.Holder.Pid == 0 {
+		return fmt.Sprintf("another run holds %s", e.Path)
+	}
+	return fmt.Sprintf("another run is active: %s; wait for it to finish", e.Holder)
+}
+
+// Lock is a held run lock.
+type Lock struct {
+	file *os.File
+	path string
+	// Stale is the holder of a lock that was left behind by a process that
+	// is no longer running, nil when the lock was free.
+	Stale *Holder
+}
+
+// Acquire takes the lock at path for command, failing with a *LockedError
+// straight away when another process holds it.
+func Acquire(path string, command string) (*Lock, error) {
+	host, _ := os.Hostname()
+	holder := Holder{Pid: os.Getpid(), Command: command, Host: host, StartedAt: time.Now()}
+	return acquire(path, holder)
+}
+
+// Check returns the holder of the lock at path without taking it, nil when
+// the lock is free.
+func Check(path string) (*Holder, error) {
+	return check(path)
+}
+
+// Release lets go of the lock. Calling it more than once is harmless.
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	err := l.release()
+	l.file = nil
+	return err
+}
+
+func (l *Lock) write(holder Holder) error {
+	err := l.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = l.file.Seek(0, 0)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(l.file).Encode(holder)
+	if err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// readHolder reads the holder from the lock file, a zero Holder when the
+// file is empty or unreadable.
+func readHolder(file *os.File) Holder {
+	var holder Holder
+	_, err := file.Seek(0, 0)
+	if err != nil {
+		return holder
+	}
+	_ = json.NewDecoder(file).Decode(&holder)
+	return holder
+}
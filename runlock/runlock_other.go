@@ -0,0 +1,113 @@
+//go:build !unix
+
+package runlock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// unreadableGrace is how long a lock file without a readable holder counts
+// as held. Locks are linked into place whole, so only a file left by an
+// older version or a damaged disk can be like that.
+const unreadableGrace = time.Minute
+
+// Without flock the lock is the file existing. The holder is written to a
+// temporary file first and linked to path, so the lock never exists without
+// its holder. A file left by a process that is no longer running is stale
+// and taken over.
+func acquire(path string, holder Holder) (*Lock, error) {
+	var stale *Holder
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := link(path, holder)
+		if err == nil {
+			return &Lock{file: file, path: path, Stale: stale}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		existing, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		current := readHolder(existing)
+		info, statErr := existing.Stat()
+		existing.Close()
+		if current.Pid == 0 {
+			if statErr != nil || time.Since(info.ModTime()) < unreadableGrace {
+				return nil, &LockedError{Path: path}
+			}
+		} else if processRunning(current.Pid) {
+			return nil, &LockedError{Path: path, Holder: current}
+		}
+		stale = &current
+		err = os.Remove(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, &LockedError{Path: path}
+}
+
+// link writes holder to a temporary file and links it to path, failing with
+// os.ErrExist when path is already there.
+func link(path string, holder Holder) (*os.File, error) {
+	tmpPath := fmt.Sprintf("%s.%d.tmp", path, holder.Pid)
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+	err = json.NewEncoder(tmp).Encode(holder)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err != nil {
+		return nil, err
+	}
+	if closeErr != nil {
+		return nil, closeErr
+	}
+	err = os.Link(tmpPath, path)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_RDWR, 0644)
+}
+
+func check(path string) (*Holder, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	holder := readHolder(file)
+	file.Close()
+	if holder.Pid == 0 || !processRunning(holder.Pid) {
+		return nil, nil
+	}
+	return &holder, nil
+}
+
+func (l *Lock) release() error {
+	closeErr := l.file.Close()
+	removeErr := os.Remove(l.path)
+	return errors.Join(closeErr, removeErr)
+}
+
+// processRunning reports whether pid is a live process. On Windows
+// FindProcess only succeeds for one.
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
@@ -0,0 +1,67 @@
+//go:build unix
+
+package runlock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func acquire(path string, holder Holder) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		current := readHolder(file)
+		file.Close()
+		return nil, &LockedError{Path: path, Holder: current}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	lock := &Lock{file: file, path: path}
+	// The file is emptied on release, so a holder still in it is one that
+	// exited without releasing; flock already let go for it.
+	if previous := readHolder(file); previous.Pid != 0 {
+		lock.Stale = &previous
+	}
+	err = lock.write(holder)
+	if err != nil {
+		lock.Release()
+		return nil, err
+	}
+	return lock, nil
+}
+
+func check(path string) (*Holder, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		holder := readHolder(file)
+		return &holder, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
+
+func (l *Lock) release() error {
+	// Empty the file while still holding the lock so the next holder does not
+	// take this one for stale.
+	truncateErr := l.file.Truncate(0)
+	unlockErr := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	closeErr := l.file.Close()
+	return errors.Join(truncateErr, unlockErr, closeErr)
+}
@@ -0,0 +1,91 @@
+//go:build unix
+
+package runlock_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mwalkersigma/drive-parser/runlock"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireConflictAndRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.lock")
+	lock, err := runlock.Acquire(path, "sweep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Stale != nil {
+		t.Errorf("free lock reported a stale holder %s", lock.Stale)
+	}
+
+	// A second open and flock of the same path conflicts even in this process.
+	_, err = runlock.Acquire(path, "cost-override")
+	var locked *runlock.LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("second Acquire err = %v, want a *LockedError", err)
+	}
+	if locked.Holder.Command != "sweep" || locked.Holder.Pid != os.Getpid() {
+		t.Errorf("locked by %s, want sweep from this process", locked.Holder)
+	}
+	holder, err := runlock.Check(path)
+	if err != nil || holder == nil || holder.Command != "sweep" {
+		t.Errorf("Check = %v, %v, want the sweep holder", holder, err)
+	}
+
+	err = lock.Release()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lock.Release()
+	if err != nil {
+		t.Errorf("second Release err = %v", err)
+	}
+	holder, err = runlock.Check(path)
+	if err != nil || holder != nil {
+		t.Errorf("Check after release = %v, %v, want a free lock", holder, err)
+	}
+	lock, err = runlock.Acquire(path, "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	if lock.Stale != nil {
+		t.Errorf("released lock reported a stale holder %s", lock.Stale)
+	}
+}
+
+func TestAcquireStaleHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.lock")
+	left := runlock.Holder{Pid: 4242, Command: "serve", Host: "old", StartedAt: time.Now().Add(-time.Hour).Truncate(time.Second)}
+	data, err := json.Marshal(left)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody holds the flock, so the holder in the file exited without
+	// releasing.
+	holder, err := runlock.Check(path)
+	if err != nil || holder != nil {
+		t.Errorf("Check = %v, %v, want a free lock", holder, err)
+	}
+	lock, err := runlock.Acquire(path, "sweep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	if lock.Stale == nil {
+		t.Fatal("lock left by an exited holder was not reported as stale")
+	}
+	stale := *lock.Stale
+	if stale.Pid != left.Pid || stale.Command != left.Command || stale.Host != left.Host || !stale.StartedAt.Equal(left.StartedAt) {
+		t.Errorf("Stale = %s, want %s", lock.Stale, left)
+	}
+}
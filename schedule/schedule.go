@@ -0,0 +1,193 @@
+// Package schedule parses cron expressions and works out when they next fire.
+//
+// Expressions have the five standard fields, minute hour day-of-month month
+// day-of-week, each a *, a number, a range a-b or a list of those, with an
+// optional /step. Months and weekdays may also be written as jan-dec and
+// sun-sat. @hourly, @daily, @weekly, @monthly and "@every <duration>" are
+// accepted as shorthands.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	expression string
+	every      time.Duration
+	minute     uint64
+	hour       uint64
+	dom        uint64
+	month      uint64
+	dow        uint64
+	// domStar and dowStar record a * day field. When both day fields are
+	// restricted a day matching either one fires, as in cron.
+	domStar bool
+	dowStar bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var shorthands = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// Parse reads a cron expression.
+func Parse(expression string) (*Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	s := &Schedule{expression: expression}
+	if every, found := strings.CutPrefix(expression, "@every "); found {
+		d, err := time.ParseDuration(strings.TrimSpace(every))
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", expression, err)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("schedule %q: runs must be at least a minute apart", expression)
+		}
+		s.every = d
+		return s, nil
+	}
+	if full, ok := shorthands[expression]; ok {
+		expression = full
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q: expected 5 fields, got %d", s.expression, len(fields))
+	}
+	var err error
+	for i, target := range []*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow} {
+		f := []field{minuteField, hourField, domField, monthField, dowField}[i]
+		*target, err = f.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", s.expression, err)
+		}
+	}
+	// Sunday is both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*"
+	s.dowStar = fields[4] == "*"
+	return s, nil
+}
+
+func (s *Schedule) String() string {
+	return s.expression
+}
+
+// Next returns the first time after t the schedule fires, in t's location.
+// It returns the zero time for a schedule that never fires, such as one for
+// the 30th of February.
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Every combination repeats within a few years, so give up after five.
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// parse turns one field into a bit set of the values it allows.
+func (f field) parse(text string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(text, ",") {
+		rangeText, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepText)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("bad step %q in %s", stepText, f.name)
+			}
+		}
+		low, high := f.min, f.max
+		if rangeText != "*" {
+			lowText, highText, isRange := strings.Cut(rangeText, "-")
+			var err error
+			low, err = f.value(lowText)
+			if err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				high, err = f.value(highText)
+				if err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = f.max
+			}
+			if high < low {
+				return 0, fmt.Errorf("bad range %q in %s", rangeText, f.name)
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(text string) (int, error) {
+	if v, ok := f.names[strings.ToLower(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("bad %s %q", f.name, text)
+	}
+	return v, nil
+}
@@ -0,0 +1,80 @@
+package schedule_test
+
+import (
+	"github.com/mwalkersigma/drive-parser/schedule"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expression string
+		from       time.Time
+		want       time.Time
+	}{
+		// 2026-10-19 is a Monday.
+		{"0 6 * * 1-5", date(2026, 10, 19, 5, 59), date(2026, 10, 19, 6, 0)},
+		{"0 6 * * 1-5", date(2026, 10, 19, 6, 0), date(2026, 10, 20, 6, 0)},
+		{"0 6 * * 1-5", date(2026, 10, 23, 7, 0), date(2026, 10, 26, 6, 0)},
+		{"*/15 * * * *", date(2026, 10, 19, 10, 7), date(2026, 10, 19, 10, 15)},
+		{"*/15 * * * *", date(2026, 10, 19, 10, 45), date(2026, 10, 19, 11, 0)},
+		{"*/15 * * * *", date(2026, 10, 19, 23, 59), date(2026, 10, 20, 0, 0)},
+		{"5-20/5 * * * *", date(2026, 10, 19, 10, 20), date(2026, 10, 19, 11, 5)},
+		// With both day fields restricted either one fires: the 13th is a
+		// Friday here, ahead of Monday the 16th.
+		{"0 0 13 * 1", date(2026, 11, 10, 0, 0), date(2026, 11, 13, 0, 0)},
+		{"0 0 13 * 1", date(2026, 11, 13, 0, 0), date(2026, 11, 16, 0, 0)},
+		// With the day of week left as * only the 13th fires.
+		{"0 0 13 * *", date(2026, 11, 13, 0, 0), date(2026, 12, 13, 0, 0)},
+		{"0 0 * * 0", date(2026, 10, 19, 0, 0), date(2026, 10, 25, 0, 0)},
+		{"0 0 * * 7", date(2026, 10, 19, 0, 0), date(2026, 10, 25, 0, 0)},
+		{"30 9 1 jan-mar *", date(2026, 10, 19, 0, 0), date(2027, 1, 1, 9, 30)},
+		{"0 12 * * SAT", date(2026, 10, 19, 0, 0), date(2026, 10, 24, 12, 0)},
+		{"0 0 29 2 *", date(2026, 10, 19, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"0 0 30 2 *", date(2026, 10, 19, 0, 0), time.Time{}},
+		{"@daily", date(2026, 10, 19, 10, 7), date(2026, 10, 20, 0, 0)},
+		{"@hourly", date(2026, 10, 19, 10, 7), date(2026, 10, 19, 11, 0)},
+		{"@every 90m", date(2026, 10, 19, 10, 7), date(2026, 10, 19, 11, 37)},
+	}
+	for _, test := range tests {
+		s, err := schedule.Parse(test.expression)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.expression, err)
+			continue
+		}
+		got := s.Next(test.from)
+		if !got.Equal(test.want) {
+			t.Errorf("%q.Next(%s) = %s, want %s", test.expression, test.from, got, test.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"20-10 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"x * * * *",
+		"* * * foo *",
+		"@yearly",
+		"@every 30s",
+		"@every soon",
+	} {
+		s, err := schedule.Parse(expression)
+		if err == nil {
+			t.Errorf("Parse(%q) = %s, want an error", expression, s)
+		}
+	}
+}
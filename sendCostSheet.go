@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/mwalkersigma/drive-parser/gservice"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+)
+
+func getSheetId(url string) string {
+	_, rest, found := strings.Cut(url, "/d/")
+	if !found {
+		return strings.TrimSpace(url)
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	return id
+}
+
+// sendCostSheetCommand sends the costs of one cost sheet to SkuVault. Without
+// a URL argument it asks for one and waits for Enter before exiting, as when
+// started by double clicking.
+func sendCostSheetCommand(args []string) int {
+	flags := commandFlags("send-cost-sheet")
+	err := flags.Parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if flags.NArg() > 1 {
+		log.Println("send-cost-sheet takes at most one url")
+		return exitUsage
+	}
+	lock, code := acquireLock("send-cost-sheet")
+	if code != exitOK {
+		return code
+	}
+	defer lock.Release()
+	// Only the SkuVault tokens are read from .env, and they may be set in the
+	// environment instead; the config and Drive are not used.
+	err = godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Println("Error loading .env")
+		log.Println(err)
+		return exitFailed
+	}
+	err = checkSkuVaultTokens()
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	sheetsService, err = gservice.NewSheetsService(context.Background(), credentialsFile("SheetCert.json"))
+	if err != nil {
+		log.Println("Error creating new service")
+		log.Println(err)
+		return exitFailed
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	interactive := flags.NArg() == 0
+	url := flags.Arg(0)
+	if interactive {
+		// get a url from the user
+		fmt.Fprint(log.Writer(), "Enter the url of the cost sheet: ")
+		url, _ = reader.ReadString('\n')
+		url = strings.TrimSpace(url)
+		defer func() {
+			log.Println("Press Enter to exit")
+			_, _ = reader.ReadString('\n')
+		}()
+	}
+	// get the cost sheet id.
+	sheetId := getSheetId(url)
+
+	log.Println("Sheet ID: ", sheetId)
+
+	// get the cost sheet data
+	costSheetData, err := sheetsService.
+		Spreadsheets.Values.
+		Get(sheetId, "Offer Template!A:P").
+		Do()
+	if err != nil {
+		log.Println("Error getting cost sheet data")
+		log.Println(err)
+		return exitFailed
+	}
+	// skip the header row and rows with no data
+	log.Println("Filtering out empty rows")
+	var rows [][]interface{}
+	if len(costSheetData.Values) > 0 {
+		rows = nonEmptyRows(costSheetData.Values[1:])
+	}
+	log.Println("Filtering complete.")
+
+	if len(rows) == 0 {
+		log.Println("No data found in the cost sheet")
+		return exitOK
+	}
+
+	err = sendToSkuVault(itemsFromCostSheet(rows))
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	if !dryRun {
+		log.Println("Items Sent to SkuVault successfully")
+	}
+	log.Println("Script Execution Complete.")
+	return exitOK
+}
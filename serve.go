@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/metrics"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/schedule"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// nextScheduledRun is when the scheduler will next start a run, zero when
+// there is no schedule.
+var nextScheduledRun time.Time
+var nextScheduledRunMu sync.Mutex
+
+// progress is what /status reports.
+type progress struct {
+	Running   bool      `json:"running"`
+	Pid       int       `json:"pid,omitempty"`
+	Trigger   string    `json:"trigger,omitempty"`
+	StartedAt time.Time `json:"startedAt,omitempty"`
+
+	FoldersFound       int64 `json:"foldersFound"`
+	FoldersResumed     int64 `json:"foldersResumed"`
+	FoldersProcessed   int64 `json:"foldersProcessed"`
+	CallsToDriveParser int64 `json:"callsToDriveParser"`
+	PosGenerated       int64 `json:"posGenerated"`
+
+	Schedule string    `json:"schedule,omitempty"`
+	NextRun  time.Time `json:"nextRun,omitempty"`
+
+	LastStart       time.Time `json:"lastStart,omitempty"`
+	LastEnd         time.Time `json:"lastEnd,omitempty"`
+	LastCompleted   bool      `json:"lastCompleted"`
+	LastAbortReason string    `json:"lastAbortReason,omitempty"`
+}
+
+func serveCommand(args []string) int {
+	flags := commandFlags("serve")
+	addRunFlags(flags)
+	err := flags.Parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if flags.NArg() > 0 {
+		log.Println("serve takes no arguments")
+		return exitUsage
+	}
+	err = setupSweep()
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	defer abortOnPanic()
+	err = startRunServices()
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	defer shutdownTracing()
+	err = serve()
+	log.Println(err)
+	return exitFailed
+}
+
+// serve runs sweeps on the configured schedule and when asked to over HTTP,
+// until the listener fails. Only one run happens at a time; a scheduled run
+// that comes up while another is going is skipped.
+func serve() error {
+	var runSchedule *schedule.Schedule
+	if config.Serve.Schedule != "" {
+		var err error
+		runSchedule, err = schedule.Parse(config.Serve.Schedule)
+		if err != nil {
+			return err
+		}
+		go runOnSchedule(runSchedule)
+	} else {
+		log.Println("No schedule set, runs only start from POST /run")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /run", func(w http.ResponseWriter, r *http.Request) {
+		options := sweepOptions{incremental: incremental, resume: resume}
+		if value := r.URL.Query().Get("incremental"); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				writeJson(w, http.StatusBadRequest, map[string]string{"error": "incremental must be true or false"})
+				return
+			}
+			options.incremental = parsed
+		}
+		err := startRun(triggerHttp, options)
+		if err != nil {
+			writeJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJson(w, http.StatusAccepted, currentProgress(runSchedule))
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, currentProgress(runSchedule))
+	})
+	mux.HandleFunc("GET /report", func(w http.ResponseWriter, r *http.Request) {
+		report, err := models.LastRunReport()
+		if err != nil {
+			writeJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if report == nil {
+			writeJson(w, http.StatusNotFound, map[string]string{"error": "no run has finished yet"})
+			return
+		}
+		writeJson(w, http.StatusOK, report)
+	})
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	log.Println("Serving at ", config.Serve.Addr)
+	return http.ListenAndServe(config.Serve.Addr, mux)
+}
+
+// runOnSchedule starts a run every time runSchedule fires.
+func runOnSchedule(runSchedule *schedule.Schedule) {
+	for {
+		next := runSchedule.Next(time.Now())
+		nextScheduledRunMu.Lock()
+		nextScheduledRun = next
+		nextScheduledRunMu.Unlock()
+		if next.IsZero() {
+			log.Println("Schedule ", runSchedule, " never fires")
+			return
+		}
+		log.Println("Next scheduled run: ", next.Format(time.RFC3339))
+		time.Sleep(time.Until(next))
+		err := startRun(triggerSchedule, sweepOptions{incremental: incremental, resume: resume})
+		if err != nil {
+			log.Println("Skipping scheduled run")
+			log.Println(err)
+		}
+	}
+}
+
+// startRun begins a run in the background, failing straight away when one
+// is already in progress.
+func startRun(trigger string, options sweepOptions) error {
+	err := beginRun(trigger)
+	if err != nil {
+		return err
+	}
+	log.Println("Starting run, triggered by ", trigger)
+	go func() {
+		// A run that panics is recorded as aborted and serve carries on
+		// with the next one.
+		defer func() {
+			if r := recover(); r != nil {
+				log.Println("Run panicked: ", r)
+				finishRun(false, fmt.Sprint(r))
+			}
+		}()
+		runSweep(options)
+	}()
+	return nil
+}
+
+func currentProgress(runSchedule *schedule.Schedule) progress {
+	runStatusMu.Lock()
+	status := runStatus
+	runStatusMu.Unlock()
+	p := progress{
+		Running:         status.Running,
+		Pid:             status.Pid,
+		Trigger:         status.Trigger,
+		StartedAt:       status.StartedAt,
+		LastStart:       status.LastStart,
+		LastEnd:         status.LastEnd,
+		LastCompleted:   status.LastCompleted,
+		LastAbortReason: status.LastAbortReason,
+	}
+	if status.Running {
+		p.FoldersFound = totalFiles.Load()
+		p.FoldersResumed = resumedFiles.Load()
+		p.FoldersProcessed = processedFiles.Load()
+		p.CallsToDriveParser = callsToDriveParser.Load()
+		p.PosGenerated = posGenerated.Load()
+	}
+	if runSchedule != nil {
+		p.Schedule = runSchedule.String()
+		nextScheduledRunMu.Lock()
+		p.NextRun = nextScheduledRun
+		nextScheduledRunMu.Unlock()
+	}
+	return p
+}
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Println("Error writing response")
+		log.Println(err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/models"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const skuVaultUpdateUrl = "https://app.skuvault.com/api/products/updateProducts"
+
+// skuVaultBatchSize is the most items SkuVault takes in one update.
+const skuVaultBatchSize = 100
+
+type Item struct {
+	Sku  string  `json:"Sku"`
+	Cost float64 `json:"Cost"`
+}
+
+func (i *Item) String() string {
+	return fmt.Sprintf(`{"Sku": "%s", "Cost": %f}`, i.Sku, i.Cost)
+}
+
+type SVRequestBody struct {
+	Items       []Item `json:"Items"`
+	UserToken   string `json:"UserToken"`
+	TenantToken string `json:"TenantToken"`
+}
+
+func (r *SVRequestBody) ToJSON() string {
+	var stringified []string
+	for _, item := range r.Items {
+		stringified = append(stringified, item.String())
+	}
+	return fmt.Sprintf(`{"Items": [%v], "UserToken": "%s", "TenantToken": "%s"}`, strings.Join(stringified, ","), r.UserToken, r.TenantToken)
+}
+
+// itemsFromCostSheet turns the rows of a cost sheet into SkuVault items at
+// the cost sent to SkuVault.
+func itemsFromCostSheet(values [][]interface{}) []Item {
+	var sheetData models.CostSheetData
+	sheetData.Values = values
+	sheetData.Parse()
+	if len(sheetData.FormattedRows) != len(values) {
+		log.Println("Missing Sku Values")
+		log.Printf("Expected Length: %d Recieved: %d", len(values), len(sheetData.FormattedRows))
+		log.Println("This is likely cause by a duplicate sku in the cost sheet")
+	}
+	var items []Item
+	for _, row := range sheetData.FormattedRows {
+		items = append(items, Item{Sku: row.Sku, Cost: float64(row.CostSentToSV)})
+	}
+	return items
+}
+
+// sendToSkuVault updates the cost of items in SkuVault, in batches of at most
+// skuVaultBatchSize, with the USER_TOKEN and TENANT_TOKEN from the
+// environment.
+func sendToSkuVault(items []Item) error {
+	if dryRun {
+		log.Printf("Dry run: not sending %d items to SkuVault\n", len(items))
+		return nil
+	}
+	err := checkSkuVaultTokens()
+	if err != nil {
+		return err
+	}
+	log.Printf("Sending %d items to SkuVault\n", len(items))
+	for start := 0; start < len(items); start += skuVaultBatchSize {
+		end := min(start+skuVaultBatchSize, len(items))
+		requestBody := SVRequestBody{
+			Items:       items[start:end],
+			UserToken:   os.Getenv("USER_TOKEN"),
+			TenantToken: os.Getenv("TENANT_TOKEN"),
+		}
+		response, err := http.Post(skuVaultUpdateUrl, "application/json", strings.NewReader(requestBody.ToJSON()))
+		if err != nil {
+			log.Println("Error updating items")
+			return err
+		}
+		response.Body.Close()
+		log.Println("Response: ", response.Status)
+	}
+	return nil
+}
+
+// nonEmptyRows drops the rows of a sheet range whose first cell is empty.
+func nonEmptyRows(values [][]interface{}) [][]interface{} {
+	var rows [][]interface{}
+	for _, row := range values {
+		if len(row) == 0 || row[0] == "" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// checkSkuVaultTokens reports a missing USER_TOKEN or TENANT_TOKEN before any
+// work is done. A dry run sends nothing, so it needs neither.
+func checkSkuVaultTokens() error {
+	if dryRun {
+		return nil
+	}
+	for _, name := range []string{"USER_TOKEN", "TENANT_TOKEN"} {
+		if os.Getenv(name) == "" {
+			return fmt.Errorf("%s is not set; put it in the environment or .env", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+// Package stats measures how long each phase of a sweep takes and what went
+// wrong, for the run statistics and the metrics endpoint.
+package stats
+
+import (
+	"context"
+	"errors"
+	"github.com/mwalkersigma/drive-parser/insightly"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/surprice"
+	"google.golang.org/api/googleapi"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Phases of a sweep that are timed.
+const (
+	PhaseEnumerate   = "enumerate"
+	PhaseCostRead    = "cost_read"
+	PhaseCostSheet   = "cost_sheet"
+	PhaseInsightly   = "insightly"
+	PhaseDriveParser = "drive_parser"
+	PhaseMove        = "move"
+	PhaseSleep       = "sleep"
+	// PhaseRetryWait is time spent backing off before retrying a call. The
+	// waits between pricing sheet reads are part of PhaseCostRead as well.
+	PhaseRetryWait = "retry_wait"
+)
+
+var phaseOrder = []string{PhaseEnumerate, PhaseCostRead, PhaseCostSheet, PhaseInsightly, PhaseDriveParser, PhaseMove, PhaseSleep, PhaseRetryWait}
+
+// Error categories.
+const (
+	ErrorRateLimited  = "rate_limited"
+	ErrorUnauthorized = "unauthorized"
+	ErrorNotFound     = "not_found"
+	ErrorServer       = "server"
+	ErrorTimeout      = "timeout"
+	ErrorCanceled     = "canceled"
+	ErrorOther        = "other"
+)
+
+// Categorize sorts an error from any of the APIs the sweep calls into one of
+// the error categories.
+func Categorize(err error) string {
+	var apiErr *googleapi.Error
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.Is(err, insightly.ErrRateLimited), errors.Is(err, surprice.ErrRateLimited):
+		return ErrorRateLimited
+	case errors.Is(err, insightly.ErrUnauthorized), errors.Is(err, surprice.ErrUnauthorized):
+		return ErrorUnauthorized
+	case errors.Is(err, insightly.ErrNotFound), errors.Is(err, surprice.ErrNotFound):
+		return ErrorNotFound
+	case errors.Is(err, insightly.ErrServer), errors.Is(err, surprice.ErrServer):
+		return ErrorServer
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.Code == http.StatusTooManyRequests:
+			return ErrorRateLimited
+		case apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden:
+			return ErrorUnauthorized
+		case apiErr.Code == http.StatusNotFound:
+			return ErrorNotFound
+		case apiErr.Code >= 500:
+			return ErrorServer
+		}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	}
+	return ErrorOther
+}
+
+type phase struct {
+	durations []time.Duration
+	errors    int
+}
+
+// Recorder collects timings and errors from every worker of a run.
+type Recorder struct {
+	mu     sync.Mutex
+	phases map[string]*phase
+	errors map[string]int
+	// observers are told about every observation as it is made.
+	observers []func(phase string, d time.Duration, err error)
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{phases: map[string]*phase{}, errors: map[string]int{}}
+}
+
+// Observe records that phaseName took d, failing with err when it is not nil.
+func (r *Recorder) Observe(phaseName string, d time.Duration, err error) {
+	r.mu.Lock()
+	p, ok := r.phases[phaseName]
+	if !ok {
+		p = &phase{}
+		r.phases[phaseName] = p
+	}
+	p.durations = append(p.durations, d)
+	if err != nil {
+		p.errors++
+		r.errors[Categorize(err)]++
+	}
+	observers := r.observers
+	r.mu.Unlock()
+	for _, observer := range observers {
+		observer(phaseName, d, err)
+	}
+}
+
+// Reset forgets every observation so the recorder can be used for another
+// run. Observers stay registered.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.phases = map[string]*phase{}
+	r.errors = map[string]int{}
+}
+
+// Since records phaseName as having started at start.
+func (r *Recorder) Since(phaseName string, start time.Time, err error) {
+	r.Observe(phaseName, time.Since(start), err)
+}
+
+// OnObserve registers fn to be called with every later observation.
+func (r *Recorder) OnObserve(fn func(phase string, d time.Duration, err error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observers = append(r.observers, fn)
+}
+
+// Total returns the summed time of phaseName.
+func (r *Recorder) Total(phaseName string) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var total time.Duration
+	if p, ok := r.phases[phaseName]; ok {
+		for _, d := range p.durations {
+			total += d
+		}
+	}
+	return total
+}
+
+// Count returns how many times phaseName was observed.
+func (r *Recorder) Count(phaseName string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.phases[phaseName]; ok {
+		return len(p.durations)
+	}
+	return 0
+}
+
+// Phases summarises every phase observed so far, in sweep order.
+func (r *Recorder) Phases() []models.PhaseStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	for _, name := range phaseOrder {
+		if _, ok := r.phases[name]; ok {
+			names = append(names, name)
+		}
+	}
+	var others []string
+	for name := range r.phases {
+		if !slices.Contains(phaseOrder, name) {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	names = append(names, others...)
+
+	var summaries []models.PhaseStats
+	for _, name := range names {
+		p := r.phases[name]
+		sorted := append([]time.Duration(nil), p.durations...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		var total time.Duration
+		for _, d := range sorted {
+			total += d
+		}
+		summaries = append(summaries, models.PhaseStats{
+			Phase:   name,
+			Count:   len(sorted),
+			Errors:  p.errors,
+			TotalMs: total.Milliseconds(),
+			P50Ms:   percentile(sorted, 0.50).Milliseconds(),
+			P95Ms:   percentile(sorted, 0.95).Milliseconds(),
+			MaxMs:   percentile(sorted, 1).Milliseconds(),
+		})
+	}
+	return summaries
+}
+
+// Errors returns the error count of each category seen so far.
+func (r *Recorder) Errors() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errorCounts := map[string]int{}
+	for category, count := range r.errors {
+		errorCounts[category] = count
+	}
+	return errorCounts
+}
+
+// percentile uses the nearest-rank method on durations sorted ascending.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
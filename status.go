@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/runlock"
+	"log"
+	"time"
+)
+
+// statusCommand prints the status file and who holds the run lock. A run is
+// only in progress while its lock is held; a status file saying otherwise
+// belongs to a run that died.
+func statusCommand(args []string) int {
+	flags := commandFlags("status")
+	err := flags.Parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	var status models.Status
+	err = status.GetStatus()
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	holder, err := runlock.Check(runlock.DefaultPath)
+	if err != nil {
+		log.Println("Error checking the run lock")
+		log.Println(err)
+		return exitFailed
+	}
+	if jsonOut != nil {
+		err = json.NewEncoder(jsonOut).Encode(struct {
+			Type string `json:"type"`
+			models.Status
+			LockHolder *runlock.Holder `json:"lockHolder,omitempty"`
+		}{"status", status, holder})
+		if err != nil {
+			log.Println(err)
+			return exitFailed
+		}
+		return exitOK
+	}
+
+	switch {
+	case holder != nil:
+		log.Println("Running: ", *holder)
+		if status.Running {
+			log.Println("Triggered by: ", status.Trigger)
+		}
+	case status.Running:
+		log.Printf("Not running. The run started %s by pid %d did not finish\n", status.StartedAt.Format(time.RFC3339), status.Pid)
+	default:
+		log.Println("Not running")
+	}
+	if status.LastStart.IsZero() {
+		log.Println("No run has finished yet")
+		return exitOK
+	}
+	log.Printf("Last run: %s to %s (%s)\n", status.LastStart.Format(time.RFC3339), status.LastEnd.Format(time.RFC3339), status.LastEnd.Sub(status.LastStart).Round(time.Second))
+	if status.LastCompleted {
+		log.Println("Last run completed")
+	} else {
+		log.Println("Last run aborted: ", status.LastAbortReason)
+	}
+	return exitOK
+}
@@ -0,0 +1,229 @@
+// Package surprice talks to the Surprice drive-parser API at BASE_URL, which
+// turns cost sheets into POs and keeps the review status of pricing sheets.
+package surprice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/ratelimit"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by the Client, wrapped with the request that caused them.
+// Callers should check them with errors.Is.
+var (
+	ErrNotFound     = errors.New("surprice: not found")
+	ErrUnauthorized = errors.New("surprice: unauthorized")
+	ErrRateLimited  = errors.New("surprice: rate limited")
+	ErrServer       = errors.New("surprice: server error")
+)
+
+const (
+	uploadPath = "/api/v1/costSheet/upload"
+	statusPath = "/api/v1/costSheet/status"
+	statsPath  = "/api/v1/costSheet/upload/run"
+)
+
+type Client struct {
+	BaseURL string
+	// APIKey is sent as a bearer token when set.
+	APIKey     string
+	HTTPClient *http.Client
+	// MaxRetries is how many times a request the API turned away with a 429
+	// or 503 is retried. Reads are also retried after 502s, 504s and network
+	// errors; writes are not, since the API may already have acted on them.
+	MaxRetries int
+	// Limiter, when set, paces every request.
+	Limiter *ratelimit.Limiter
+}
+
+// NewClient returns a Client for the API at BASE_URL, authenticating with
+// SURPRICE_API_KEY when it is set.
+func NewClient() *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(os.Getenv("BASE_URL"), "/"),
+		APIKey:     os.Getenv("SURPRICE_API_KEY"),
+		HTTPClient: &http.Client{Timeout: 60 * time.Second * 5},
+		MaxRetries: 3,
+	}
+}
+
+// UploadCostSheet asks the API to create a PO from the cost sheet at sheetUrl.
+// A rejected sheet is not an error; it comes back with Error set and the
+// reason in Message.
+func (c *Client) UploadCostSheet(ctx context.Context, sheetUrl string) (*models.DriveParserResponse, error) {
+	var response models.DriveParserResponse
+	err := c.do(ctx, http.MethodPost, uploadPath, models.CostSheetUploadRequest{Url: sheetUrl}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// MarkSheet records why a pricing sheet needs attention.
+func (c *Client) MarkSheet(ctx context.Context, request models.SheetStatusRequest) (*models.DriveStatusResponse, error) {
+	var response models.DriveStatusResponse
+	err := c.do(ctx, http.MethodPost, statusPath, request, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *Client) GetSheetStatus(ctx context.Context, sheetID string) (*models.DriveStatusResponse, error) {
+	var response models.DriveStatusResponse
+	err := c.do(ctx, http.MethodGet, statusPath+"/"+sheetID, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *Client) SendStats(ctx context.Context, stats *models.Statistics) error {
+	return c.do(ctx, http.MethodPost, statsPath, stats, nil)
+}
+
+// do sends body, when not nil, as JSON and decodes the response into target,
+// when not nil.
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, target interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	request := method + " " + path
+	for attempt := 0; ; attempt++ {
+		err := c.Limiter.Wait(ctx)
+		if err != nil {
+			return err
+		}
+		var reqBody io.Reader
+		if payload != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reqBody)
+		if err != nil {
+			return err
+		}
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.APIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+c.APIKey)
+		}
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			if method == http.MethodGet && attempt < c.MaxRetries && ctx.Err() == nil {
+				err = c.backoff(ctx, request, nil, attempt)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("surprice: %s: %w", request, err)
+		}
+		if c.shouldRetry(method, resp.StatusCode) && attempt < c.MaxRetries {
+			closeBody(resp.Body)
+			err = c.backoff(ctx, request, resp, attempt)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err = decodeResponse(resp, request, target)
+		closeBody(resp.Body)
+		return err
+	}
+}
+
+func (c *Client) shouldRetry(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method == http.MethodGet
+	}
+	return false
+}
+
+func (c *Client) backoff(ctx context.Context, request string, resp *http.Response, attempt int) error {
+	wait := retryAfter(resp, attempt)
+	log.Printf("Surprice %s failed. Retrying in %s\n", request, wait)
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// decodeResponse turns the status into one of the package errors. The API
+// reports a sheet it could not handle with a JSON message rather than a
+// successful status, so an error status carrying a message is decoded like a
+// success and left for the caller to read.
+func decodeResponse(resp *http.Response, request string, target interface{}) error {
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, request)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %s returned %s", ErrUnauthorized, request, resp.Status)
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", ErrRateLimited, request)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("surprice: reading %s: %w", request, err)
+	}
+	ok := resp.StatusCode >= 200 && resp.StatusCode <= 299
+	if !ok {
+		var message struct {
+			Message string `json:"message"`
+		}
+		if target == nil || json.Unmarshal(data, &message) != nil || message.Message == "" {
+			if resp.StatusCode >= 500 {
+				return fmt.Errorf("%w: %s returned %s", ErrServer, request, resp.Status)
+			}
+			return fmt.Errorf("surprice: %s returned %s", request, resp.Status)
+		}
+	}
+	if target == nil {
+		return nil
+	}
+	err = json.Unmarshal(data, target)
+	if err != nil {
+		return fmt.Errorf("surprice: decoding %s: %w: %s", request, err, data)
+	}
+	return nil
+}
+
+// retryAfter honours the Retry-After header and otherwise backs off
+// exponentially from one second.
+func retryAfter(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return time.Second << attempt
+}
+
+func closeBody(body io.ReadCloser) {
+	err := body.Close()
+	if err != nil {
+		log.Println("Error closing body")
+	}
+}
@@ -0,0 +1,308 @@
+// Package surpricetest serves a stand-in for the Surprice drive-parser API so
+// the sweep can run against scripted responses, from tests, from httptest or
+// as the fakeSurprice command.
+package surpricetest
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/models"
+	"github.com/mwalkersigma/drive-parser/surprice"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// Names of the bundled fixtures, captured from the real server unless noted.
+const (
+	FixturePoCreated          = "po-created"
+	FixturePoAlreadyExists    = "po-already-exists"
+	FixtureAlreadyProcessed   = "already-processed"
+	FixtureSupplierUnknown    = "supplier-unknown"
+	FixtureItemsSkipped       = "items-skipped"
+	FixtureUpstream502        = "upstream-502"
+	FixtureServiceUnavailable = "service-unavailable"
+	// FixtureSupplierUnknownNoError carries the supplier message without the
+	// error flag, which the sweep treats as accepted.
+	FixtureSupplierUnknownNoError = "supplier-unknown-no-error"
+)
+
+// markedMessage is what the real server answers a successful status update
+// with.
+const markedMessage = "Sheet has been marked with failure reason"
+
+// Response is one scripted answer. Body is sent as JSON unless it is null.
+// With Drop set the connection is closed without an answer instead, so the
+// client sees a transport error.
+type Response struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+	Drop   bool            `json:"drop,omitempty"`
+}
+
+// Fixture returns the bundled fixture called name.
+func Fixture(name string) (Response, error) {
+	data, err := fixtures.ReadFile(path.Join("fixtures", name+".json"))
+	if err != nil {
+		return Response{}, fmt.Errorf("surpricetest: no fixture %s", name)
+	}
+	return decodeResponse(data)
+}
+
+// Fixtures lists the names of the bundled fixtures.
+func Fixtures() []string {
+	entries, _ := fixtures.ReadDir("fixtures")
+	var names []string
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadResponse reads a response saved in the fixture format, so a response
+// captured from the real server can be replayed.
+func LoadResponse(filePath string) (Response, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return Response{}, err
+	}
+	return decodeResponse(data)
+}
+
+func decodeResponse(data []byte) (Response, error) {
+	var response Response
+	err := json.Unmarshal(data, &response)
+	if err != nil {
+		return Response{}, err
+	}
+	if response.Status == 0 {
+		response.Status = http.StatusOK
+	}
+	return response, nil
+}
+
+// Call is a request received by the stand-in.
+type Call struct {
+	Method string
+	Path   string
+	Body   string
+	Time   time.Time
+}
+
+// Fake implements the cost sheet endpoints. Uploads are answered from the
+// script for the sheet URL, one response per call with the last one
+// repeating, and from Default when the sheet has no script.
+type Fake struct {
+	mu       sync.Mutex
+	mux      *http.ServeMux
+	defaults Response
+	scripts  map[string][]Response
+	statuses map[string]models.DriveStatusData
+	stats    []models.Statistics
+	calls    []Call
+}
+
+// New returns a Fake answering every upload with the PO created fixture.
+func New() *Fake {
+	created, err := Fixture(FixturePoCreated)
+	if err != nil {
+		panic(err)
+	}
+	f := &Fake{
+		defaults: created,
+		scripts:  map[string][]Response{},
+		statuses: map[string]models.DriveStatusData{},
+	}
+	f.mux = http.NewServeMux()
+	f.mux.HandleFunc("POST /api/v1/costSheet/upload", f.upload)
+	f.mux.HandleFunc("POST /api/v1/costSheet/upload/run", f.run)
+	f.mux.HandleFunc("POST /api/v1/costSheet/status", f.markSheet)
+	f.mux.HandleFunc("GET /api/v1/costSheet/status/{sheetID}", f.sheetStatus)
+	return f
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Method: r.Method, Path: r.URL.Path, Body: string(body), Time: time.Now()})
+	f.mu.Unlock()
+	f.mux.ServeHTTP(w, r)
+}
+
+// SetDefault replaces the answer to uploads of sheets without a script.
+func (f *Fake) SetDefault(response Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.defaults = response
+}
+
+// Script queues responses for uploads of sheetUrl.
+func (f *Fake) Script(sheetUrl string, responses ...Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scripts[sheetUrl] = append(f.scripts[sheetUrl], responses...)
+}
+
+// SetStatus sets what the status endpoint reports for sheetID.
+func (f *Fake) SetStatus(sheetID string, status models.DriveStatusData) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses[sheetID] = status
+}
+
+func (f *Fake) Status(sheetID string) (models.DriveStatusData, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status, ok := f.statuses[sheetID]
+	return status, ok
+}
+
+// Calls returns every request received so far.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CallsTo returns the requests received for method and path.
+func (f *Fake) CallsTo(method string, path string) []Call {
+	var matching []Call
+	for _, call := range f.Calls() {
+		if call.Method == method && call.Path == path {
+			matching = append(matching, call)
+		}
+	}
+	return matching
+}
+
+// Stats returns the run statistics posted so far.
+func (f *Fake) Stats() []models.Statistics {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]models.Statistics(nil), f.stats...)
+}
+
+func (f *Fake) upload(w http.ResponseWriter, r *http.Request) {
+	var request models.CostSheetUploadRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, `{"error":true,"message":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	response := f.defaults
+	script := f.scripts[request.Url]
+	if len(script) > 0 {
+		response = script[0]
+		if len(script) > 1 {
+			f.scripts[request.Url] = script[1:]
+		}
+	}
+	f.mu.Unlock()
+	writeResponse(w, response)
+}
+
+func (f *Fake) run(w http.ResponseWriter, r *http.Request) {
+	var stats models.Statistics
+	err := json.NewDecoder(r.Body).Decode(&stats)
+	if err != nil {
+		http.Error(w, `{"error":true,"message":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.stats = append(f.stats, stats)
+	f.mu.Unlock()
+	writeJSON(w, map[string]interface{}{"error": false, "message": "Run recorded"})
+}
+
+func (f *Fake) markSheet(w http.ResponseWriter, r *http.Request) {
+	var request models.SheetStatusRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, `{"error":true,"message":"Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	status := f.statuses[request.SheetID]
+	status.SheetId = request.SheetID
+	status.SheetName = request.Title
+	status.SheetFailureReason = request.Reason
+	status.IsReviewed = false
+	f.statuses[request.SheetID] = status
+	f.mu.Unlock()
+	writeJSON(w, models.DriveStatusResponse{Message: markedMessage, Data: status})
+}
+
+func (f *Fake) sheetStatus(w http.ResponseWriter, r *http.Request) {
+	sheetID := r.PathValue("sheetID")
+	f.mu.Lock()
+	status, ok := f.statuses[sheetID]
+	f.mu.Unlock()
+	if !ok {
+		status = models.DriveStatusData{SheetId: sheetID}
+	}
+	writeJSON(w, models.DriveStatusResponse{Data: status})
+}
+
+func writeResponse(w http.ResponseWriter, response Response) {
+	if response.Drop {
+		hijacker, ok := w.(http.Hijacker)
+		if ok {
+			conn, _, err := hijacker.Hijack()
+			if err == nil {
+				_ = conn.Close()
+				return
+			}
+		}
+		http.Error(w, "connection dropped", http.StatusInternalServerError)
+		return
+	}
+	if len(response.Body) == 0 || string(response.Body) == "null" {
+		w.WriteHeader(response.Status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.Status)
+	_, _ = w.Write(response.Body)
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Server runs a Fake on a local httptest server.
+type Server struct {
+	*httptest.Server
+	*Fake
+}
+
+// NewServer starts a stand-in with New's defaults. Close it when done.
+func NewServer() *Server {
+	fake := New()
+	return &Server{Server: httptest.NewServer(fake), Fake: fake}
+}
+
+// Client returns a surprice.Client pointed at the stand-in.
+func (s *Server) Client() *surprice.Client {
+	client := surprice.NewClient()
+	client.BaseURL = s.URL
+	client.HTTPClient = s.Server.Client()
+	return client
+}
@@ -0,0 +1,227 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OtlpExporter sends spans to an OpenTelemetry collector as OTLP/HTTP JSON,
+// POSTing each batch to {Endpoint}/v1/traces.
+type OtlpExporter struct {
+	Endpoint   string
+	Headers    map[string]string
+	HTTPClient *http.Client
+}
+
+// NewOtlpExporter returns an exporter for the collector at endpoint. headers
+// is in the OTEL_EXPORTER_OTLP_HEADERS form, key=value pairs split by commas.
+func NewOtlpExporter(endpoint string, headers string) *OtlpExporter {
+	exporter := &OtlpExporter{
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		Headers:    map[string]string{},
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, pair := range strings.Split(headers, ",") {
+		key, value, found := strings.Cut(pair, "=")
+		if found {
+			exporter.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return exporter
+}
+
+func (e *OtlpExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint+"/v1/traces", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("otlp export: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+func (e *OtlpExporter) Shutdown(ctx context.Context) error {
+	e.HTTPClient.CloseIdleConnections()
+	return nil
+}
+
+// The types below are the OTLP JSON encoding of an ExportTraceServiceRequest.
+// IDs are hex and timestamps are nanoseconds sent as strings.
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// otlpRequest groups spans by resource and instrumentation scope.
+func otlpRequest(spans []sdktrace.ReadOnlySpan) otlpTraces {
+	var request otlpTraces
+	resources := map[string]int{}
+	scopes := map[string]int{}
+	for _, span := range spans {
+		resourceKey := span.Resource().Encoded(attribute.DefaultEncoder())
+		r, ok := resources[resourceKey]
+		if !ok {
+			r = len(request.ResourceSpans)
+			resources[resourceKey] = r
+			request.ResourceSpans = append(request.ResourceSpans, otlpResourceSpans{
+				Resource: otlpResource{Attributes: otlpAttributes(span.Resource().Attributes())},
+			})
+		}
+		resourceSpans := &request.ResourceSpans[r]
+		scope := span.InstrumentationScope()
+		scopeKey := resourceKey + "|" + scope.Name + "|" + scope.Version
+		s, ok := scopes[scopeKey]
+		if !ok {
+			s = len(resourceSpans.ScopeSpans)
+			scopes[scopeKey] = s
+			resourceSpans.ScopeSpans = append(resourceSpans.ScopeSpans, otlpScopeSpans{
+				Scope: otlpScope{Name: scope.Name, Version: scope.Version},
+			})
+		}
+		scopeSpans := &resourceSpans.ScopeSpans[s]
+		scopeSpans.Spans = append(scopeSpans.Spans, otlpSpanOf(span))
+	}
+	return request
+}
+
+func otlpSpanOf(span sdktrace.ReadOnlySpan) otlpSpan {
+	converted := otlpSpan{
+		TraceId:           span.SpanContext().TraceID().String(),
+		SpanId:            span.SpanContext().SpanID().String(),
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()),
+		StartTimeUnixNano: nanos(span.StartTime()),
+		EndTimeUnixNano:   nanos(span.EndTime()),
+		Attributes:        otlpAttributes(span.Attributes()),
+	}
+	if span.Parent().IsValid() {
+		converted.ParentSpanId = span.Parent().SpanID().String()
+	}
+	for _, event := range span.Events() {
+		converted.Events = append(converted.Events, otlpEvent{
+			TimeUnixNano: nanos(event.Time),
+			Name:         event.Name,
+			Attributes:   otlpAttributes(event.Attributes),
+		})
+	}
+	// OTLP numbers the status codes OK = 1 and ERROR = 2, the other way round
+	// from the otel codes package.
+	switch span.Status().Code {
+	case codes.Ok:
+		converted.Status.Code = 1
+	case codes.Error:
+		converted.Status.Code = 2
+		converted.Status.Message = span.Status().Description
+	}
+	return converted
+}
+
+func otlpAttributes(attributes []attribute.KeyValue) []otlpKeyValue {
+	var converted []otlpKeyValue
+	for _, kv := range attributes {
+		var value otlpAnyValue
+		switch kv.Value.Type() {
+		case attribute.BOOL:
+			b := kv.Value.AsBool()
+			value.BoolValue = &b
+		case attribute.INT64:
+			i := strconv.FormatInt(kv.Value.AsInt64(), 10)
+			value.IntValue = &i
+		case attribute.FLOAT64:
+			f := kv.Value.AsFloat64()
+			value.DoubleValue = &f
+		default:
+			s := kv.Value.Emit()
+			value.StringValue = &s
+		}
+		converted = append(converted, otlpKeyValue{Key: string(kv.Key), Value: value})
+	}
+	return converted
+}
+
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,128 @@
+// Package tracing sets up the OpenTelemetry tracer the sweep records its
+// spans with. Each folder is one trace; the Drive, Sheets, Insightly and
+// Drive Parser calls made for it are child spans of the folder span.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/metrics"
+	"github.com/mwalkersigma/drive-parser/models"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const instrumentationName = "github.com/mwalkersigma/drive-parser"
+
+var provider *sdktrace.TracerProvider
+var shutdownOnce sync.Once
+
+// Setup installs the tracer provider for the exporter in config. With no
+// exporter configured spans are still created but go nowhere.
+func Setup(config models.TracingConfig) error {
+	var exporter sdktrace.SpanExporter
+	switch config.Exporter {
+	case models.TracingExporterNone:
+		return nil
+	case models.TracingExporterFile:
+		file, err := os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			return err
+		}
+		exporter = &closingExporter{SpanExporter: exporter, file: file}
+	case models.TracingExporterOtlp:
+		endpoint := config.Endpoint
+		if endpoint == "" {
+			endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		}
+		if endpoint == "" {
+			return fmt.Errorf("tracing exporter otlp needs an endpoint")
+		}
+		exporter = NewOtlpExporter(endpoint, os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	default:
+		return fmt.Errorf("unknown tracing exporter %q", config.Exporter)
+	}
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = "drive-parser"
+	}
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return nil
+}
+
+// Shutdown flushes the spans still queued and stops the exporter. It is safe
+// to call more than once, and from the signal handler.
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	var err error
+	shutdownOnce.Do(func() {
+		err = provider.Shutdown(ctx)
+	})
+	return err
+}
+
+// Tracer returns the tracer for the sweep's own spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start begins a span as a child of whatever span is in ctx.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End records err on span, if there is one, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Transport makes a client span for every request sent through base, named
+// after the API and the operation the same way the metrics label them.
+func Transport(base http.RoundTripper, api string) http.RoundTripper {
+	return otelhttp.NewTransport(base,
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return api + " " + metrics.Operation(req)
+		}),
+		otelhttp.WithSpanOptions(trace.WithAttributes(attribute.String("api", api))),
+	)
+}
+
+// closingExporter closes the trace file once the exporter is shut down.
+type closingExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (e *closingExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	closeErr := e.file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/mwalkersigma/drive-parser/models"
+	"log"
+	"strings"
+)
+
+// opportunityIdFromName returns the Insightly opportunity ID carried in the
+// third dash-separated part of a sheet name, or "" when there is none.
+func opportunityIdFromName(name string) string {
+	parts := strings.Split(name, "-")
+	if len(parts) < 3 {
+		return ""
+	}
+	return strings.TrimSpace(parts[2])
+}
+
+// writeBackToInsightly applies the configured update for event to the
+// folder's opportunity. The Drive side has already happened by now, so a
+// failure is logged and recorded in the report but does not fail the folder.
+func writeBackToInsightly(ctx context.Context, logger *log.Logger, entry *models.FolderReport, event string, values models.WriteBackValues) {
+	writeBack := config.Insightly.WriteBack
+	update, ok := writeBack.Update(event)
+	if !ok {
+		return
+	}
+	oppId := entry.OpportunityId
+	if oppId == "" {
+		oppId = opportunityIdFromName(entry.SheetName)
+	}
+	if oppId == "" {
+		logger.Println("No opportunity ID found, not updating Insightly")
+		return
+	}
+	values.SheetName = entry.SheetName
+	dry := writeBack.DryRun || dryRun
+	record := func(description string, err error) {
+		if dry {
+			description = "dry run: " + description
+		} else if err != nil {
+			description = fmt.Sprintf("failed: %s: %s", description, err)
+		}
+		logger.Printf("Insightly update for %s -> %s\n", oppId, description)
+		entry.InsightlyUpdates = append(entry.InsightlyUpdates, description)
+	}
+
+	if update.NoteBody != "" {
+		title := values.Expand(update.NoteTitle)
+		body := values.Expand(update.NoteBody)
+		var err error
+		if !dry {
+			err = insightlyClient.AddNote(ctx, oppId, title, body)
+		}
+		record(fmt.Sprintf("add note %q: %s", title, body), err)
+	}
+	if update.CustomField != "" {
+		value := values.Expand(update.CustomFieldValue)
+		var err error
+		if !dry {
+			err = insightlyClient.SetCustomField(ctx, oppId, update.CustomField, value)
+		}
+		record(fmt.Sprintf("set %s to %q", update.CustomField, value), err)
+	}
+	if update.StageId != 0 {
+		var err error
+		if !dry {
+			err = insightlyClient.SetStage(ctx, oppId, update.StageId)
+		}
+		record(fmt.Sprintf("move to stage %d", update.StageId), err)
+	}
+}